@@ -11,21 +11,29 @@ type ArcheType struct {
 	enitityIds []EntityId
 	components map[reflect.Type]cmpInterface
 
-	entityIdxMap map[EntityId]int
+	// entities has location of entities, it is shared with registry
+	entities *entityTable
 
 	debugComponentStr []string
 }
 
 type cmpInterface interface {
 	onAddEntity(idx int)
+	onAddEntities(n int)
 	onRemoveEntity(idx, lastIdx int)
-	copyDataToOtherArcheType(acc *ArcheTypeAccessor, other *ArcheType) error
+	onClear()
+	copyDataToOtherArcheType(idx int, other *ArcheType, otherIdx int) error
+	copyAllToOtherArcheType(other *ArcheType, otherStart int)
 }
 
 func newArcheType(types ...reflect.Type) *ArcheType {
+	return newArcheTypeWithTable(&entityTable{}, types...)
+}
+
+func newArcheTypeWithTable(entities *entityTable, types ...reflect.Type) *ArcheType {
 	a := &ArcheType{
-		components:   make(map[reflect.Type]cmpInterface),
-		entityIdxMap: make(map[EntityId]int),
+		components: make(map[reflect.Type]cmpInterface),
+		entities:   entities,
 	}
 	for _, t := range types {
 		a.components[t] = nil
@@ -44,10 +52,19 @@ func (a *ArcheType) hasComponent(t reflect.Type) bool {
 }
 
 func (a *ArcheType) hasEntity(entityId EntityId) bool {
-	_, found := a.entityIdxMap[entityId]
+	_, found := a.getEntityIdx(entityId)
 	return found
 }
 
+// getEntityIdx returns row of entity in this archetype
+func (a *ArcheType) getEntityIdx(entityId EntityId) (int, bool) {
+	rec := a.entities.get(entityId)
+	if rec == nil || rec.archeType != a {
+		return 0, false
+	}
+	return rec.row, true
+}
+
 func HasArcheTypeComponent[T any](a *ArcheType) bool {
 	var t T
 	return a.hasComponent(reflect.TypeOf(t))
@@ -58,6 +75,7 @@ func (a *ArcheType) getComponentTypeList() []reflect.Type {
 	var i int
 	for t := range a.components {
 		typeList[i] = t
+		i++
 	}
 	return typeList
 }
@@ -75,42 +93,62 @@ func (a *ArcheType) equalComponents(types []reflect.Type) bool {
 	return true
 }
 
-func (a *ArcheType) addEntity(entityId EntityId) {
-	_, added := a.entityIdxMap[entityId]
+// addEntity adds entity and returns its row, location of entity is changed to this archetype
+func (a *ArcheType) addEntity(entityId EntityId) int {
+	idx, added := a.getEntityIdx(entityId)
 	if added {
 		// already added
-		return
+		return idx
 	}
-	idx := len(a.enitityIds)
+	idx = len(a.enitityIds)
 	a.enitityIds = append(a.enitityIds, entityId)
-	a.entityIdxMap[entityId] = idx
+	a.entities.setLocation(entityId, a, idx)
 
 	for _, v := range a.components {
 		if v != nil {
 			v.onAddEntity(idx)
 		}
 	}
+	return idx
+}
+
+// addEntities adds entities that are not added yet at once and returns row of first entity
+func (a *ArcheType) addEntities(entityIds []EntityId) int {
+	start := len(a.enitityIds)
+	a.enitityIds = append(a.enitityIds, entityIds...)
+	for i, entityId := range entityIds {
+		a.entities.setLocation(entityId, a, start+i)
+	}
+	for _, v := range a.components {
+		if v != nil {
+			v.onAddEntities(len(entityIds))
+		}
+	}
+	return start
 }
 
 func (a *ArcheType) removeEntity(entityId EntityId) {
-	idx, found := a.entityIdxMap[entityId]
+	idx, found := a.getEntityIdx(entityId)
 	if !found {
 		// not added
 		return
 	}
+	a.removeEntityByIdx(idx)
+}
+
+// removeEntityByIdx removes row by swapping with last row, location of removed entity is not changed
+func (a *ArcheType) removeEntityByIdx(idx int) {
 	lastIdx := len(a.enitityIds) - 1
 	lastEntityId := a.enitityIds[lastIdx]
 
-	delete(a.entityIdxMap, entityId)
-
 	if idx == lastIdx {
 		// remove last
 		a.enitityIds = a.enitityIds[:lastIdx]
 	} else {
 		// swap
 		a.enitityIds[idx] = lastEntityId
-		a.entityIdxMap[lastEntityId] = idx
-		slices.Delete(a.enitityIds, lastIdx, lastIdx+1)
+		a.entities.setLocation(lastEntityId, a, idx)
+		a.enitityIds = slices.Delete(a.enitityIds, lastIdx, lastIdx+1)
 	}
 	for _, v := range a.components {
 		if v != nil {
@@ -119,13 +157,23 @@ func (a *ArcheType) removeEntity(entityId EntityId) {
 	}
 }
 
-func (a *ArcheType) copyDataToOtherArcheType(entityId EntityId, ty reflect.Type, other *ArcheType) error {
+// clear removes all entities, locations of entities are not changed
+func (a *ArcheType) clear() {
+	a.enitityIds = a.enitityIds[:0]
+	for _, v := range a.components {
+		if v != nil {
+			v.onClear()
+		}
+	}
+}
+
+func (a *ArcheType) copyDataToOtherArcheType(idx int, ty reflect.Type, other *ArcheType, otherIdx int) error {
 	cmp := a.components[ty]
 	if cmp == nil {
 		// no data
 		return nil
 	}
-	return cmp.copyDataToOtherArcheType(a.GetAccessor(entityId), other)
+	return cmp.copyDataToOtherArcheType(idx, other, otherIdx)
 }
 
 type compData[T any] struct {
@@ -146,28 +194,47 @@ func (c *compData[T]) onAddEntity(idx int) {
 	c.arr = append(c.arr, t)
 }
 
+func (c *compData[T]) onAddEntities(n int) {
+	c.arr = slices.Grow(c.arr, n)
+	c.arr = c.arr[:len(c.arr)+n]
+	clear(c.arr[len(c.arr)-n:])
+}
+
 func (c *compData[T]) onRemoveEntity(idx, lastIdx int) {
 	if lastIdx != len(c.arr)-1 {
 		panic("lastIdx should be same with last index of array")
 	}
 	c.arr[idx] = c.arr[lastIdx]
-	slices.Delete(c.arr, lastIdx, lastIdx+1)
+	c.arr = slices.Delete(c.arr, lastIdx, lastIdx+1)
 }
 
-func (c *compData[T]) copyDataToOtherArcheType(acc *ArcheTypeAccessor, other *ArcheType) error {
-	otherAcc := other.GetAccessor(acc.entityId)
-	if otherAcc == nil {
-		return errors.Errorf("other Archetype doesn't have entityId %v", acc.entityId)
+func (c *compData[T]) onClear() {
+	clear(c.arr)
+	c.arr = c.arr[:0]
+}
+
+func (c *compData[T]) copyDataToOtherArcheType(idx int, other *ArcheType, otherIdx int) error {
+	if otherIdx < 0 || otherIdx >= len(other.enitityIds) {
+		return errors.Errorf("other Archetype doesn't have index %d", otherIdx)
 	}
-	success := setArcheTypeComponentByIdx[T](other, otherAcc.idx, c.arr[acc.idx])
+	success := setArcheTypeComponentByIdx[T](other, otherIdx, c.arr[idx])
 	if !success {
 		return errors.Errorf("failed to set archetype data")
 	}
 	return nil
 }
 
+// copyAllToOtherArcheType copies all rows to rows of other archetype from otherStart
+func (c *compData[T]) copyAllToOtherArcheType(other *ArcheType, otherStart int) {
+	dst := getCompData[T](other)
+	if dst == nil {
+		return
+	}
+	copy(dst.arr[otherStart:], c.arr)
+}
+
 func getArcheTypeComponent[T any](a *ArcheType, entityId EntityId) *T {
-	idx, found := a.entityIdxMap[entityId]
+	idx, found := a.getEntityIdx(entityId)
 	if !found {
 		return nil
 	}
@@ -175,28 +242,34 @@ func getArcheTypeComponent[T any](a *ArcheType, entityId EntityId) *T {
 	return getArcheTypeComponentByIdx[T](a, idx)
 }
 
-func getArcheTypeComponentByIdx[T any](a *ArcheType, idx int) *T {
-	if idx < 0 || idx >= len(a.enitityIds) {
-		return nil
-	}
-
+// getCompData returns component data of T, it is made at first access. nil if archetype doesn't have T
+func getCompData[T any](a *ArcheType) *compData[T] {
 	var t T
-	var cmpData *compData[T]
 	v, found := a.components[reflect.TypeOf(t)]
 	if !found {
 		return nil
 	}
 	if v == nil {
-		cmpData = newCompData[T](len(a.enitityIds))
+		cmpData := newCompData[T](len(a.enitityIds))
 		a.components[reflect.TypeOf(t)] = cmpData
-	} else {
-		cmpData = v.(*compData[T])
+		return cmpData
+	}
+	return v.(*compData[T])
+}
+
+func getArcheTypeComponentByIdx[T any](a *ArcheType, idx int) *T {
+	if idx < 0 || idx >= len(a.enitityIds) {
+		return nil
+	}
+	cmpData := getCompData[T](a)
+	if cmpData == nil {
+		return nil
 	}
 	return &cmpData.arr[idx]
 }
 
 func setArcheTypeComponent[T any](a *ArcheType, entityId EntityId, value T) bool {
-	idx, found := a.entityIdxMap[entityId]
+	idx, found := a.getEntityIdx(entityId)
 	if !found {
 		// entity is not added
 		return false
@@ -205,17 +278,10 @@ func setArcheTypeComponent[T any](a *ArcheType, entityId EntityId, value T) bool
 }
 
 func setArcheTypeComponentByIdx[T any](a *ArcheType, idx int, value T) bool {
-	var cmpData *compData[T]
-	v, found := a.components[reflect.TypeOf(value)]
-	if !found {
+	cmpData := getCompData[T](a)
+	if cmpData == nil {
 		return false
 	}
-	if v == nil {
-		cmpData = newCompData[T](len(a.enitityIds))
-		a.components[reflect.TypeOf(value)] = cmpData
-	} else {
-		cmpData = v.(*compData[T])
-	}
 	cmpData.arr[idx] = value
 	return true
 }
@@ -227,7 +293,7 @@ type ArcheTypeAccessor struct {
 }
 
 func (a *ArcheType) GetAccessor(entityId EntityId) *ArcheTypeAccessor {
-	idx, found := a.entityIdxMap[entityId]
+	idx, found := a.getEntityIdx(entityId)
 	if !found {
 		return nil
 	}
@@ -62,3 +62,40 @@ func TestArcheType(t *testing.T) {
 	assert.NotNil(t, velComp)
 	assert.Equal(t, 100, velComp.Vel)
 }
+
+func TestArcheTypeSwapRemove(t *testing.T) {
+	r := NewRegistry()
+
+	var entities []EntityId
+	for i := 0; i < 5; i++ {
+		e, err := r.CreateEntityImmediate()
+		assert.NoError(t, err)
+		assert.NoError(t, AddComponentImmediate(r, e, PositionComponent{X: i}))
+		entities = append(entities, e)
+	}
+	a := r.entities.getArcheType(entities[0])
+	assert.Equal(t, 5, a.getEntityCount())
+
+	// remove first, last entity is swapped into its row
+	assert.NoError(t, r.RemoveEntityImmediate(entities[0]))
+	assert.Equal(t, 4, a.getEntityCount())
+	assert.Equal(t, entities[4], a.enitityIds[0])
+	for i := 1; i < 5; i++ {
+		acc := a.GetAccessor(entities[i])
+		assert.NotNil(t, acc)
+		assert.Equal(t, entities[i], acc.GetEntityId())
+		assert.Equal(t, i, GetComponentByAccessor[PositionComponent](acc).X)
+	}
+	assert.Nil(t, a.GetAccessor(entities[0]))
+
+	// move to other archetype keeps data
+	assert.NoError(t, AddComponentImmediate(r, entities[2], VelocityComponent{Vel: 7}))
+	assert.Equal(t, 3, a.getEntityCount())
+	a2 := r.entities.getArcheType(entities[2])
+	assert.NotEqual(t, a, a2)
+	assert.Equal(t, 2, getArcheTypeComponent[PositionComponent](a2, entities[2]).X)
+	assert.Equal(t, 7, getArcheTypeComponent[VelocityComponent](a2, entities[2]).Vel)
+	for _, i := range []int{1, 3, 4} {
+		assert.Equal(t, i, getArcheTypeComponent[PositionComponent](a, entities[i]).X)
+	}
+}
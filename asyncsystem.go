@@ -0,0 +1,181 @@
+package ecsgo
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// AsyncStartFn starts background work of async system, it is called like SystemFn when previous work is synced.
+// ctx is live context of tick, not a copy. Start runs as system of tick with its declared queries,
+// so reading components is ordered with other systems, and copying whole world for every start is avoided.
+// ctx, query results and component pointers are valid only until start returns,
+// so work should capture copies of what it needs because it runs concurrently with next ticks.
+// Returning nil work means nothing to start on this tick.
+type AsyncStartFn func(ctx *ExecutionContext) (AsyncWorkFn, error)
+
+// AsyncWorkFn runs in background across ticks, structural changes should be recorded by commands.
+// ctx is derived from context of Tick that started work and is cancelled by AsyncSystem.Cancel or Registry.Close
+type AsyncWorkFn func(ctx context.Context, commands *CommandBuffer) error
+
+// AsyncSystem is system that runs work in background across ticks,
+// commands of work are played back at sync point of first Tick after work is done
+type AsyncSystem struct {
+	*System
+	startFn AsyncStartFn
+
+	mx      sync.Mutex
+	running bool
+	// done is true when work is done and waiting for sync
+	done     bool
+	cancel   context.CancelFunc
+	commands *CommandBuffer
+	err      error
+	wg       sync.WaitGroup
+}
+
+// AddAsyncSystem adds async system, queries can be added to it like System
+func (r *Registry) AddAsyncSystem(name string, priority int, fn AsyncStartFn) *AsyncSystem {
+	as := &AsyncSystem{
+		startFn: fn,
+	}
+	as.System = r.AddSystem(name, priority, as.start)
+	r.asyncMx.Lock()
+	r.asyncSystems = append(r.asyncSystems, as)
+	r.asyncMx.Unlock()
+	return as
+}
+
+// IsRunning returns true if work is running or waiting for sync
+func (as *AsyncSystem) IsRunning() bool {
+	as.mx.Lock()
+	defer as.mx.Unlock()
+	return as.running || as.done
+}
+
+// Cancel cancels running work, its commands are dropped
+func (as *AsyncSystem) Cancel() {
+	as.mx.Lock()
+	defer as.mx.Unlock()
+	if as.cancel != nil {
+		as.cancel()
+	}
+}
+
+// wait waits until work returns
+func (as *AsyncSystem) wait() {
+	as.wg.Wait()
+}
+
+func (as *AsyncSystem) start(ctx *ExecutionContext) error {
+	as.mx.Lock()
+	busy := as.running || as.done
+	as.mx.Unlock()
+	if busy {
+		return nil
+	}
+
+	work, err := as.startFn(ctx)
+	if err != nil || work == nil {
+		return err
+	}
+
+	tickCtx := ctx.GetContext()
+	if tickCtx == nil {
+		tickCtx = context.Background()
+	}
+	workCtx, cancel := context.WithCancel(tickCtx)
+	commands := newCommandBuffer(as.registry)
+	commands.background = true
+
+	as.mx.Lock()
+	as.running = true
+	as.cancel = cancel
+	as.mx.Unlock()
+
+	as.wg.Add(1)
+	go func() {
+		defer as.wg.Done()
+		err := work(workCtx, commands)
+		if err == nil && workCtx.Err() != nil {
+			// cancelled work is dropped
+			err = workCtx.Err()
+		}
+
+		as.mx.Lock()
+		as.running = false
+		as.done = true
+		as.cancel = nil
+		as.commands = commands
+		as.err = err
+		as.mx.Unlock()
+		cancel()
+	}()
+	return nil
+}
+
+// sync plays back commands of done work, cancelled work is dropped without error
+func (as *AsyncSystem) sync() error {
+	as.mx.Lock()
+	defer as.mx.Unlock()
+	if !as.done {
+		return nil
+	}
+	as.done = false
+	err := as.err
+	commands := as.commands
+	as.err = nil
+	as.commands = nil
+
+	if err != nil {
+		// commands of failed or cancelled work are dropped
+		commands.Discard()
+	}
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "async system %s failed", as.name)
+	}
+	as.registry.deferredActions.playback(commands)
+	return nil
+}
+
+// syncAsyncSystems syncs done async systems in registration order and returns first error
+func (r *Registry) syncAsyncSystems() error {
+	r.asyncMx.Lock()
+	asyncSystems := r.asyncSystems
+	r.asyncMx.Unlock()
+
+	var firstErr error
+	for _, as := range asyncSystems {
+		err := as.sync()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// cancelAsyncSystems cancels all running works and waits until they return, results are dropped
+func (r *Registry) cancelAsyncSystems() {
+	r.asyncMx.Lock()
+	asyncSystems := r.asyncSystems
+	r.asyncMx.Unlock()
+
+	for _, as := range asyncSystems {
+		as.Cancel()
+	}
+	for _, as := range asyncSystems {
+		as.wait()
+		as.mx.Lock()
+		as.done = false
+		if as.commands != nil {
+			as.commands.Discard()
+		}
+		as.commands = nil
+		as.err = nil
+		as.mx.Unlock()
+	}
+}
@@ -0,0 +1,120 @@
+package ecsgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func waitAsyncDone(as *AsyncSystem) {
+	for {
+		as.mx.Lock()
+		done := as.done
+		as.mx.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func countComponent[T any](r *Registry) int {
+	var count int
+	for _, a := range r.archeTypeList {
+		if HasArcheTypeComponent[T](a) {
+			count += a.getEntityCount()
+		}
+	}
+	return count
+}
+
+func TestAsyncSystem(t *testing.T) {
+	r := NewRegistry()
+	defer r.Close()
+	for i := 1; i <= 10; i++ {
+		e := r.CreateEntity()
+		AddComponent(r, e, TestComponent1{X: i})
+	}
+	assert.NoError(t, r.Flush())
+
+	release := make(chan struct{})
+	var started int
+	var workErr error
+	var lastCreated EntityId
+	as := r.AddAsyncSystem("planner", 0, func(ctx *ExecutionContext) (AsyncWorkFn, error) {
+		started++
+		// copy what work needs
+		var sum int
+		ctx.GetQueryResult(0).ForeachEntities(func(accessor *ArcheTypeAccessor) error {
+			sum += GetComponentByAccessor[TestComponent1](accessor).X
+			return nil
+		})
+		return func(ctx context.Context, commands *CommandBuffer) error {
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			e := commands.CreateEntity()
+			AddComponentCommand(commands, e, TestComponent2{V: float64(sum)})
+			lastCreated = e
+			return workErr
+		}, nil
+	})
+	AddReadonlyComponent[TestComponent1](as.NewQuery())
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, r.Tick(time.Millisecond, ctx))
+	}
+	// work spans ticks and is not started again
+	assert.Equal(t, 1, started)
+	assert.True(t, as.IsRunning())
+	assert.Equal(t, 0, countComponent[TestComponent2](r))
+
+	release <- struct{}{}
+	waitAsyncDone(as)
+	assert.NoError(t, r.Tick(time.Millisecond, ctx))
+	assert.Equal(t, 1, countComponent[TestComponent2](r))
+	assert.False(t, as.IsRunning())
+
+	// started again on next tick
+	assert.NoError(t, r.Tick(time.Millisecond, ctx))
+	assert.Equal(t, 2, started)
+
+	// cancelled work is dropped
+	as.Cancel()
+	waitAsyncDone(as)
+	assert.NoError(t, r.Tick(time.Millisecond, ctx))
+	assert.Equal(t, 1, countComponent[TestComponent2](r))
+
+	// error is returned at sync point
+	workErr = errors.New("work error")
+	assert.NoError(t, r.Tick(time.Millisecond, ctx))
+	assert.Equal(t, 3, started)
+	release <- struct{}{}
+	waitAsyncDone(as)
+	assert.ErrorIs(t, r.Tick(time.Millisecond, ctx), workErr)
+	// id issued by failed work is released, it is already written to entity table so removed by flush
+	assert.NoError(t, r.Flush())
+	assert.False(t, r.IsActiveEntity(lastCreated))
+
+	// cancelled with context of Tick
+	workErr = nil
+	tickCtx, cancel := context.WithCancel(ctx)
+	assert.NoError(t, r.Tick(time.Millisecond, tickCtx))
+	assert.Equal(t, 4, started)
+	cancel()
+	waitAsyncDone(as)
+	assert.NoError(t, r.Tick(time.Millisecond, ctx))
+	assert.Equal(t, 1, countComponent[TestComponent2](r))
+
+	// close cancels running work
+	assert.NoError(t, r.Tick(time.Millisecond, ctx))
+	assert.True(t, as.IsRunning())
+	r.Close()
+	assert.False(t, as.IsRunning())
+}
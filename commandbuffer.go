@@ -0,0 +1,85 @@
+package ecsgo
+
+type command struct {
+	entityId EntityId
+	action   entityAction
+}
+
+// CommandBuffer records entity actions without lock and plays them back to registry at once.
+// CommandBuffer is not safe for concurrent use, use one buffer per goroutine.
+type CommandBuffer struct {
+	registry *Registry
+	commands []command
+	// background is true for buffer of async work, its ids are not recorded
+	// because work runs again on replay and issues ids by itself
+	background bool
+}
+
+func newCommandBuffer(registry *Registry) *CommandBuffer {
+	return &CommandBuffer{
+		registry: registry,
+	}
+}
+
+// CreateEntity issues new entity id immediately, entity is created when buffer is played back
+func (cb *CommandBuffer) CreateEntity() EntityId {
+	entityId := cb.registry.issueEntityId()
+	if !cb.background {
+		cb.registry.deferredActions.recordReserve(entityId)
+	}
+	cb.commands = append(cb.commands, command{entityId: entityId, action: &createEntityAction{}})
+	return entityId
+}
+
+func (cb *CommandBuffer) RemoveEntity(entityId EntityId) {
+	cb.commands = append(cb.commands, command{entityId: entityId, action: &removeEntityAction{}})
+}
+
+func AddComponentCommand[T any](cb *CommandBuffer, entityId EntityId, val T) {
+	cb.commands = append(cb.commands, command{entityId: entityId, action: &addComponentAction[T]{val: val}})
+}
+
+func RemoveComponentCommand[T any](cb *CommandBuffer, entityId EntityId) {
+	cb.commands = append(cb.commands, command{entityId: entityId, action: &removeComponentAction[T]{}})
+}
+
+func (cb *CommandBuffer) Len() int {
+	return len(cb.commands)
+}
+
+// Reset discards commands, buffer can be reused after Reset
+func (cb *CommandBuffer) Reset() {
+	cb.Discard()
+}
+
+// Discard drops commands that are not played back.
+// Ids issued by CreateEntity of dropped commands are released, so they are not left as empty entities
+func (cb *CommandBuffer) Discard() {
+	var created []EntityId
+	for _, cmd := range cb.commands {
+		if _, ok := cmd.action.(*createEntityAction); ok {
+			created = append(created, cmd.entityId)
+		}
+	}
+	if len(created) > 0 {
+		released := cb.registry.releaseEntityIds(created)
+		if !cb.background {
+			for _, entityId := range released {
+				cb.registry.deferredActions.recordRelease(entityId)
+			}
+		}
+	}
+	cb.clearCommands()
+}
+
+// clearCommands clears commands that are moved to other place
+func (cb *CommandBuffer) clearCommands() {
+	clear(cb.commands)
+	cb.commands = cb.commands[:0]
+}
+
+// appendBuffer moves commands of other buffer to the end of this buffer
+func (cb *CommandBuffer) appendBuffer(other *CommandBuffer) {
+	cb.commands = append(cb.commands, other.commands...)
+	other.clearCommands()
+}
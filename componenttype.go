@@ -0,0 +1,228 @@
+package ecsgo
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ComponentType is registered information of component type, it is used for serialization
+type ComponentType struct {
+	name       string
+	ty         reflect.Type
+	transient  bool
+	replicated bool
+
+	// pod is true if type is plain old data that can be copied by memory
+	pod   bool
+	codec ComponentCodec
+
+	hashMode componentHashMode
+	// rawHashable is true if memory of value can be hashed directly
+	rawHashable bool
+
+	// newValue returns pointer of new zero value
+	newValue func() any
+	// getValue returns pointer of value at idx, it is nil if archetype doesn't have component
+	getValue func(a *ArcheType, idx int) any
+	// setValue sets value at idx by pointer of value
+	setValue func(a *ArcheType, idx int, ptr any) bool
+	// appendColumn appends memory of all values in archetype, only for pod
+	appendColumn func(buf []byte, a *ArcheType) []byte
+	// restoreColumn copies memory to values from start row, only for pod
+	restoreColumn func(a *ArcheType, start int, data []byte) error
+	// rowBytes returns memory of value at idx, only for rawHashable
+	rowBytes func(a *ArcheType, idx int) []byte
+	// addComponent adds component by pointer of value as deferred action
+	addComponent func(r *Registry, entityId EntityId, ptr any)
+	// removeComponent removes component as deferred action
+	removeComponent func(r *Registry, entityId EntityId)
+}
+
+type componentHashMode int
+
+const (
+	// hash if component is not transient
+	hashDefault componentHashMode = iota
+	hashOn
+	hashOff
+)
+
+var componentTypes = struct {
+	mx     sync.RWMutex
+	byName map[string]*ComponentType
+	byType map[reflect.Type]*ComponentType
+}{
+	byName: make(map[string]*ComponentType),
+	byType: make(map[reflect.Type]*ComponentType),
+}
+
+// RegisterComponent registers component type with name that is used in saved data.
+// It panics if name or type is already registered with different one.
+func RegisterComponent[T any](name string) *ComponentType {
+	var t T
+	ty := reflect.TypeOf(t)
+
+	componentTypes.mx.Lock()
+	defer componentTypes.mx.Unlock()
+
+	if ct, found := componentTypes.byName[name]; found {
+		if ct.ty != ty {
+			panic(fmt.Sprintf("ecsgo: component name %s is already registered by %v", name, ct.ty))
+		}
+		return ct
+	}
+	if ct, found := componentTypes.byType[ty]; found {
+		panic(fmt.Sprintf("ecsgo: component %v is already registered as %s", ty, ct.name))
+	}
+
+	ct := &ComponentType{
+		name: name,
+		ty:   ty,
+		newValue: func() any {
+			return new(T)
+		},
+		getValue: func(a *ArcheType, idx int) any {
+			v := getArcheTypeComponentByIdx[T](a, idx)
+			if v == nil {
+				return nil
+			}
+			return v
+		},
+		setValue: func(a *ArcheType, idx int, ptr any) bool {
+			return setArcheTypeComponentByIdx[T](a, idx, *ptr.(*T))
+		},
+		appendColumn:  appendPODColumn[T],
+		restoreColumn: restorePODColumn[T],
+		rowBytes:      rowBytes[T],
+		addComponent: func(r *Registry, entityId EntityId, ptr any) {
+			AddComponent(r, entityId, *ptr.(*T))
+		},
+		removeComponent: RemoveComponent[T],
+	}
+	ct.pod = isPOD(ty)
+	ct.rawHashable = isRawHashable(ty)
+	if !ct.pod && implementsBinaryMarshaler(ty) {
+		ct.codec = binaryMarshalerCodec{}
+	}
+	componentTypes.byName[name] = ct
+	componentTypes.byType[ty] = ct
+	return ct
+}
+
+func getComponentTypeByName(name string) *ComponentType {
+	componentTypes.mx.RLock()
+	defer componentTypes.mx.RUnlock()
+	return componentTypes.byName[name]
+}
+
+func getComponentType(ty reflect.Type) *ComponentType {
+	componentTypes.mx.RLock()
+	defer componentTypes.mx.RUnlock()
+	return componentTypes.byType[ty]
+}
+
+func (ct *ComponentType) GetName() string {
+	return ct.name
+}
+
+func (ct *ComponentType) GetType() reflect.Type {
+	return ct.ty
+}
+
+// SetTransient - transient component is not saved
+func (ct *ComponentType) SetTransient(transient bool) {
+	ct.transient = transient
+}
+
+func (ct *ComponentType) IsTransient() bool {
+	return ct.transient
+}
+
+// SetReplicated - replicated component is sent to clients by Replicator
+func (ct *ComponentType) SetReplicated(replicated bool) {
+	ct.replicated = replicated
+}
+
+func (ct *ComponentType) IsReplicated() bool {
+	return ct.replicated
+}
+
+// SetHashed sets whether component is included in Registry.Hash,
+// by default all components are hashed except transient components
+func (ct *ComponentType) SetHashed(hashed bool) {
+	if hashed {
+		ct.hashMode = hashOn
+	} else {
+		ct.hashMode = hashOff
+	}
+}
+
+func (ct *ComponentType) isHashed() bool {
+	switch ct.hashMode {
+	case hashOn:
+		return true
+	case hashOff:
+		return false
+	}
+	return !ct.transient
+}
+
+// ComponentCodec encodes component that is not plain old data in binary snapshot
+type ComponentCodec interface {
+	// AppendComponent appends encoded value of ptr to buf, ptr is pointer of component
+	AppendComponent(buf []byte, ptr any) ([]byte, error)
+	// DecodeComponent decodes data to ptr, ptr is pointer of component
+	DecodeComponent(data []byte, ptr any) error
+}
+
+// SetCodec sets codec for binary snapshot. Component that contains pointer, slice, map or string needs codec
+// unless its pointer implements encoding.BinaryMarshaler and encoding.BinaryUnmarshaler
+func (ct *ComponentType) SetCodec(codec ComponentCodec) {
+	ct.codec = codec
+}
+
+// isPOD returns true if type doesn't contain any pointer so it can be copied by memory
+func isPOD(ty reflect.Type) bool {
+	switch ty.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	case reflect.Array:
+		return isPOD(ty.Elem())
+	case reflect.Struct:
+		for i := 0; i < ty.NumField(); i++ {
+			if !isPOD(ty.Field(i).Type) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+var (
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+func implementsBinaryMarshaler(ty reflect.Type) bool {
+	ptrTy := reflect.PointerTo(ty)
+	return ptrTy.Implements(binaryMarshalerType) && ptrTy.Implements(binaryUnmarshalerType)
+}
+
+type binaryMarshalerCodec struct{}
+
+func (binaryMarshalerCodec) AppendComponent(buf []byte, ptr any) ([]byte, error) {
+	data, err := ptr.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return buf, err
+	}
+	return append(buf, data...), nil
+}
+
+func (binaryMarshalerCodec) DecodeComponent(data []byte, ptr any) error {
+	return ptr.(encoding.BinaryUnmarshaler).UnmarshalBinary(data)
+}
@@ -4,20 +4,33 @@ import (
 	"reflect"
 	"slices"
 	"sync"
+	"sync/atomic"
 )
 
 type entityAction interface {
-	modifyTypes(types, added, removed *[]reflect.Type)
+	// modifyTypes returns error if action is not valid for types, then action is skipped
+	modifyTypes(types, added, removed *[]reflect.Type) error
 	apply(entityId EntityId, a *ArcheType)
+	// describe returns operation name and component type for error reporting
+	describe() (op string, ty reflect.Type)
 }
 
 type deferredActions struct {
 	r *Registry
 
-	entityActions      map[EntityId][]entityAction
+	entityActions map[EntityId][]entityAction
+	// entityOrder keeps order of entities when their first action is recorded,
+	// actions are processed in this order instead of map iteration order to be deterministic
+	entityOrder []EntityId
+
 	addSystemActions   []*System
 	addObserverActions []*Observer
 
+	// errors found while recording, reported on next flush
+	recordErrs []error
+	// flushing is true while actions are processed, actions recorded by observers are not written to recorder
+	flushing atomic.Bool
+
 	mx sync.Mutex
 }
 
@@ -30,100 +43,176 @@ func newDeferredActions(r *Registry) *deferredActions {
 
 type createEntityAction struct{}
 
-func (a *createEntityAction) modifyTypes(types, added, removed *[]reflect.Type) {}
-func (a *createEntityAction) apply(entityId EntityId, archeType *ArcheType)     {}
+func (a *createEntityAction) modifyTypes(types, added, removed *[]reflect.Type) error { return nil }
+func (a *createEntityAction) apply(entityId EntityId, archeType *ArcheType)           {}
+func (a *createEntityAction) describe() (string, reflect.Type)                        { return "CreateEntity", nil }
 
 type removeEntityAction struct{}
 
-func (a *removeEntityAction) modifyTypes(types, added, removed *[]reflect.Type) {}
-func (a *removeEntityAction) apply(entityId EntityId, archeType *ArcheType)     {}
+func (a *removeEntityAction) modifyTypes(types, added, removed *[]reflect.Type) error { return nil }
+func (a *removeEntityAction) apply(entityId EntityId, archeType *ArcheType)           {}
+func (a *removeEntityAction) describe() (string, reflect.Type)                        { return "RemoveEntity", nil }
 
 type addComponentAction[T any] struct {
 	val T
 }
 
-func (a *addComponentAction[T]) modifyTypes(types, added, removed *[]reflect.Type) {
+func (a *addComponentAction[T]) modifyTypes(types, added, removed *[]reflect.Type) error {
 	var ty reflect.Type = reflect.TypeOf(a.val)
+	if slices.Contains(*types, ty) {
+		// already has component, only value is changed
+		return nil
+	}
 	*types = append(*types, ty)
 	*added = append(*added, ty)
+	return nil
 }
 
 func (a *addComponentAction[T]) apply(entityId EntityId, archeType *ArcheType) {
 	setArcheTypeComponent[T](archeType, entityId, a.val)
 }
 
+func (a *addComponentAction[T]) describe() (string, reflect.Type) {
+	return "AddComponent", reflect.TypeOf(a.val)
+}
+
+func (a *addComponentAction[T]) componentValue() any {
+	return a.val
+}
+
 type removeComponentAction[T any] struct{}
 
-func (a *removeComponentAction[T]) modifyTypes(types, added, removed *[]reflect.Type) {
+func (a *removeComponentAction[T]) modifyTypes(types, added, removed *[]reflect.Type) error {
 	var t T
 	var ty reflect.Type = reflect.TypeOf(t)
+	if !slices.Contains(*types, ty) {
+		return ErrComponentMissing
+	}
 	*types = slices.DeleteFunc(*types, func(t reflect.Type) bool {
 		return ty == t
 	})
 	*removed = append(*removed, ty)
+	return nil
 }
 
 func (a *removeComponentAction[T]) apply(entityId EntityId, archeType *ArcheType) {
 	// nothing to change
 }
 
+func (a *removeComponentAction[T]) describe() (string, reflect.Type) {
+	var t T
+	return "RemoveComponent", reflect.TypeOf(t)
+}
+
+// setActions should be called with lock
+func (d *deferredActions) setActions(entityId EntityId, actions []entityAction) {
+	if _, found := d.entityActions[entityId]; !found {
+		d.entityOrder = append(d.entityOrder, entityId)
+	}
+	d.entityActions[entityId] = actions
+}
+
+// appendAction should be called with lock
+func (d *deferredActions) appendAction(entityId EntityId, action entityAction) {
+	d.recordEvent(entityId, action)
+	actions := d.entityActions[entityId]
+	// check if it is already removed
+	if len(actions) == 1 {
+		if _, ok := actions[0].(*removeEntityAction); ok {
+			// it is already removed
+			d.recordErrs = append(d.recordErrs, newEntityError(action, entityId, ErrStaleEntity))
+			return
+		}
+	}
+	d.setActions(entityId, append(actions, action))
+}
+
+// recordAction should be called with lock
+func (d *deferredActions) recordAction(entityId EntityId, action entityAction) {
+	switch action.(type) {
+	case *createEntityAction:
+		d.recordEvent(entityId, action)
+		_, found := d.entityActions[entityId]
+		if found {
+			// create entity should be called at first
+			d.recordErrs = append(d.recordErrs, newEntityError(action, entityId, ErrEntityAlreadyCreated))
+			return
+		}
+		d.setActions(entityId, []entityAction{action})
+	case *removeEntityAction:
+		d.recordEvent(entityId, action)
+		d.setActions(entityId, []entityAction{action})
+	default:
+		d.appendAction(entityId, action)
+	}
+}
+
+// recordEvent writes action to recorder if it is called out of tick and flush, it should be called with lock
+func (d *deferredActions) recordEvent(entityId EntityId, action entityAction) {
+	if d.isRecording() {
+		d.r.recorder.recordAction(entityId, action)
+	}
+}
+
+// recordReserve writes id that is issued by command buffer, so replay issues ids in same order
+func (d *deferredActions) recordReserve(entityId EntityId) {
+	if d.isRecording() {
+		d.r.recorder.recordReserve(entityId)
+	}
+}
+
+// recordRelease writes id that is released by discarded command buffer
+func (d *deferredActions) recordRelease(entityId EntityId) {
+	if d.isRecording() {
+		d.r.recorder.recordRelease(entityId)
+	}
+}
+
+// isRecording returns true if mutation is made by user, mutations during tick and flush are reproduced by replay
+func (d *deferredActions) isRecording() bool {
+	return d.r.recorder != nil && !d.r.isTicking() && !d.flushing.Load()
+}
+
 func (d *deferredActions) createEntity(entityId EntityId) {
 	d.mx.Lock()
 	defer d.mx.Unlock()
 
-	_, found := d.entityActions[entityId]
-	if found {
-		// create entity should be called at first
-		panic("createEntity should be called at first")
-	}
-	d.entityActions[entityId] = []entityAction{&createEntityAction{}}
+	d.recordAction(entityId, &createEntityAction{})
 }
 
 func (d *deferredActions) removeEntity(entityId EntityId) {
 	d.mx.Lock()
 	defer d.mx.Unlock()
 
-	d.entityActions[entityId] = []entityAction{&removeEntityAction{}}
+	d.recordAction(entityId, &removeEntityAction{})
 }
 
-func addComponentDeferredAction[T any](d *deferredActions, entityId EntityId, val T) {
+// playback records all commands in buffer at once in recorded order and reset buffer
+func (d *deferredActions) playback(cb *CommandBuffer) {
+	if cb.Len() == 0 {
+		return
+	}
 	d.mx.Lock()
 	defer d.mx.Unlock()
 
-	actions, found := d.entityActions[entityId]
-	if !found {
-		d.entityActions[entityId] = []entityAction{&addComponentAction[T]{val: val}}
-	}
-	// check if it is already removed
-	if len(actions) == 1 {
-		if _, ok := (actions)[0].(*removeEntityAction); ok {
-			// it is already removed
-			return
-		}
+	for _, cmd := range cb.commands {
+		d.recordAction(cmd.entityId, cmd.action)
 	}
+	cb.clearCommands()
+}
 
-	actions = append(actions, &addComponentAction[T]{val: val})
-	d.entityActions[entityId] = actions
+func addComponentDeferredAction[T any](d *deferredActions, entityId EntityId, val T) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	d.appendAction(entityId, &addComponentAction[T]{val: val})
 }
 
 func removeComponentDeferredAction[T any](d *deferredActions, entityId EntityId) {
 	d.mx.Lock()
 	defer d.mx.Unlock()
 
-	actions, found := d.entityActions[entityId]
-	if !found {
-		d.entityActions[entityId] = []entityAction{&removeComponentAction[T]{}}
-	}
-	// check if it is already removed
-	if len(actions) == 1 {
-		if _, ok := (actions)[0].(*removeEntityAction); ok {
-			// it is already removed
-			return
-		}
-	}
-
-	actions = append(actions, &removeComponentAction[T]{})
-	d.entityActions[entityId] = actions
+	d.appendAction(entityId, &removeComponentAction[T]{})
 }
 
 func (d *deferredActions) addSystem(sys *System) {
@@ -141,35 +230,81 @@ func (d *deferredActions) addObserver(o *Observer) {
 }
 
 func (d *deferredActions) process() error {
+	d.flushing.Store(true)
+	defer d.flushing.Store(false)
+
 	for _, o := range d.addObserverActions {
 		d.r.addObserverSync(o)
 	}
 	d.addObserverActions = d.addObserverActions[:0]
 
-	var err error
-	for entityId, actions := range d.entityActions {
+	err := d.processRecordErrs()
+	if err != nil {
+		return err
+	}
+
+	d.r.issueReservedIds()
+	// entityOrder can grow while processing when observers record new actions
+	for i := 0; i < len(d.entityOrder); i++ {
+		// observers can create entities while processing
+		d.r.issueReservedIds()
+		entityId := d.entityOrder[i]
+		actions, found := d.entityActions[entityId]
+		if !found {
+			continue
+		}
+		// delete before processing, so failed entity is not processed twice on next flush
+		delete(d.entityActions, entityId)
 		if len(actions) == 0 {
 			continue
 		}
-		if len(actions) == 1 {
-			if _, ok := actions[0].(*removeEntityAction); ok {
-				err = d.r.removeEntitySync(entityId)
-				if err != nil {
-					return err
-				}
-			}
+		if _, ok := actions[0].(*removeEntityAction); ok && len(actions) == 1 {
+			err = d.r.removeEntitySync(entityId)
+		} else {
+			err = d.r.processEntityActionSync(entityId, actions)
 		}
-
-		err = d.r.processEntityActionSync(entityId, actions)
 		if err != nil {
+			// keep remained entities for next flush
+			d.entityOrder = slices.Delete(d.entityOrder, 0, i+1)
 			return err
 		}
-		delete(d.entityActions, entityId)
 	}
+	d.entityOrder = d.entityOrder[:0]
 
 	for _, sys := range d.addSystemActions {
 		d.r.addSystemSync(sys)
 	}
 	d.addSystemActions = d.addSystemActions[:0]
+	return d.r.flushDeferredObservers()
+}
+
+func (d *deferredActions) resetEntityActions() {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	clear(d.entityActions)
+	d.entityOrder = d.entityOrder[:0]
+}
+
+// reportError reports error that is found out of flush, it is handled on next flush
+func (d *deferredActions) reportError(err error) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	d.recordErrs = append(d.recordErrs, err)
+}
+
+func (d *deferredActions) processRecordErrs() error {
+	d.mx.Lock()
+	errs := d.recordErrs
+	d.recordErrs = nil
+	d.mx.Unlock()
+
+	for _, err := range errs {
+		err = d.r.errHandler.handle(err)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
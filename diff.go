@@ -0,0 +1,230 @@
+package ecsgo
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// WorldDiff is difference from one world state to another
+type WorldDiff struct {
+	// Created are entities that exist only in new state
+	Created []EntityId
+	// Removed are entities that exist only in old state
+	Removed []EntityId
+	// ArcheTypeChanges are entities that exist in both states with different components
+	ArcheTypeChanges []ArcheTypeChange
+	// ComponentChanges are different field values of components that exist in both states
+	ComponentChanges []ComponentChange
+}
+
+// ArcheTypeChange is change of component set of entity
+type ArcheTypeChange struct {
+	EntityId EntityId
+	From     []string
+	To       []string
+}
+
+// ComponentChange is change of field value, Field is path of field like "Pos.X", empty if component itself
+type ComponentChange struct {
+	EntityId  EntityId
+	Component string
+	Field     string
+	From      string
+	To        string
+}
+
+// Diff compares two registries and returns what is changed from a to b, it is sorted by entity id
+func Diff(a, b *Registry) *WorldDiff {
+	d := &WorldDiff{}
+	aEntities := a.collectEntities()
+	bEntities := b.collectEntities()
+
+	for _, entityId := range sortedEntityIds(aEntities) {
+		if _, found := bEntities[entityId]; !found {
+			d.Removed = append(d.Removed, entityId)
+		}
+	}
+	for _, entityId := range sortedEntityIds(bEntities) {
+		from, found := aEntities[entityId]
+		if !found {
+			d.Created = append(d.Created, entityId)
+			continue
+		}
+		d.diffEntity(entityId, from, bEntities[entityId])
+	}
+	return d
+}
+
+// DiffSnapshots compares two snapshots that are made by Registry.Snapshot
+func DiffSnapshots(a, b []byte) (*WorldDiff, error) {
+	ra := NewRegistry()
+	if err := ra.Restore(a); err != nil {
+		return nil, err
+	}
+	rb := NewRegistry()
+	if err := rb.Restore(b); err != nil {
+		return nil, err
+	}
+	return Diff(ra, rb), nil
+}
+
+// IsEmpty returns true if there is no difference
+func (d *WorldDiff) IsEmpty() bool {
+	return len(d.Created) == 0 && len(d.Removed) == 0 &&
+		len(d.ArcheTypeChanges) == 0 && len(d.ComponentChanges) == 0
+}
+
+// String returns human readable report
+func (d *WorldDiff) String() string {
+	if d.IsEmpty() {
+		return "no difference"
+	}
+	var sb strings.Builder
+	for _, entityId := range d.Created {
+		fmt.Fprintf(&sb, "+ entity %v\n", entityId)
+	}
+	for _, entityId := range d.Removed {
+		fmt.Fprintf(&sb, "- entity %v\n", entityId)
+	}
+	for _, c := range d.ArcheTypeChanges {
+		fmt.Fprintf(&sb, "~ entity %v components [%s] -> [%s]\n", c.EntityId, strings.Join(c.From, ", "), strings.Join(c.To, ", "))
+	}
+	for _, c := range d.ComponentChanges {
+		name := c.Component
+		if c.Field != "" {
+			name += "." + c.Field
+		}
+		fmt.Fprintf(&sb, "~ entity %v %s: %s -> %s\n", c.EntityId, name, c.From, c.To)
+	}
+	return sb.String()
+}
+
+type diffEntity struct {
+	archeType *ArcheType
+	row       int
+}
+
+func (r *Registry) collectEntities() map[EntityId]diffEntity {
+	entities := make(map[EntityId]diffEntity)
+	for id, rec := range r.entities.records {
+		if rec.alive {
+			entities[EntityId{id: uint32(id), version: rec.version}] = diffEntity{archeType: rec.archeType, row: rec.row}
+		}
+	}
+	return entities
+}
+
+func sortedEntityIds(entities map[EntityId]diffEntity) []EntityId {
+	entityIds := make([]EntityId, 0, len(entities))
+	for entityId := range entities {
+		entityIds = append(entityIds, entityId)
+	}
+	slices.SortFunc(entityIds, func(a, b EntityId) int {
+		return int(a.id) - int(b.id)
+	})
+	return entityIds
+}
+
+// componentNames returns sorted names of components with its types
+func componentNames(a *ArcheType) ([]string, map[string]reflect.Type) {
+	types := make(map[string]reflect.Type)
+	if a == nil {
+		return nil, types
+	}
+	var names []string
+	for _, ty := range a.getComponentTypeList() {
+		name := ty.String()
+		if ct := getComponentType(ty); ct != nil {
+			name = ct.name
+		}
+		names = append(names, name)
+		types[name] = ty
+	}
+	slices.Sort(names)
+	return names, types
+}
+
+func (d *WorldDiff) diffEntity(entityId EntityId, from, to diffEntity) {
+	fromNames, fromTypes := componentNames(from.archeType)
+	toNames, toTypes := componentNames(to.archeType)
+	if !slices.Equal(fromNames, toNames) {
+		d.ArcheTypeChanges = append(d.ArcheTypeChanges, ArcheTypeChange{
+			EntityId: entityId,
+			From:     fromNames,
+			To:       toNames,
+		})
+	}
+	// values of components that exist in both
+	for _, name := range toNames {
+		ty, found := fromTypes[name]
+		if !found || ty != toTypes[name] {
+			continue
+		}
+		fromVal := getComponentValue(from.archeType, from.row, ty)
+		toVal := getComponentValue(to.archeType, to.row, ty)
+		d.diffValue(entityId, name, "", fromVal, toVal)
+	}
+}
+
+// diffValue compares values field by field and appends changes of leaf values
+func (d *WorldDiff) diffValue(entityId EntityId, component, field string, from, to reflect.Value) {
+	switch from.Kind() {
+	case reflect.Struct:
+		for i := 0; i < from.NumField(); i++ {
+			d.diffValue(entityId, component, joinField(field, from.Type().Field(i).Name), from.Field(i), to.Field(i))
+		}
+		return
+	case reflect.Array:
+		for i := 0; i < from.Len(); i++ {
+			d.diffValue(entityId, component, fmt.Sprintf("%s[%d]", field, i), from.Index(i), to.Index(i))
+		}
+		return
+	case reflect.Slice:
+		if from.Len() == to.Len() && !from.IsNil() && !to.IsNil() {
+			for i := 0; i < from.Len(); i++ {
+				d.diffValue(entityId, component, fmt.Sprintf("%s[%d]", field, i), from.Index(i), to.Index(i))
+			}
+			return
+		}
+	case reflect.Map:
+		if from.Len() == to.Len() && !from.IsNil() && !to.IsNil() {
+			keys := from.MapKeys()
+			// keys of other map are different, so compare as a whole
+			if !slices.ContainsFunc(keys, func(k reflect.Value) bool { return !to.MapIndex(k).IsValid() }) {
+				slices.SortFunc(keys, func(a, b reflect.Value) int {
+					return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+				})
+				for _, k := range keys {
+					d.diffValue(entityId, component, fmt.Sprintf("%s[%v]", field, k), from.MapIndex(k), to.MapIndex(k))
+				}
+				return
+			}
+		}
+	case reflect.Pointer:
+		if !from.IsNil() && !to.IsNil() {
+			d.diffValue(entityId, component, field, from.Elem(), to.Elem())
+			return
+		}
+	}
+	// compare by hash bytes, it works for unexported fields also
+	if bytes.Equal(appendHashValue(nil, from), appendHashValue(nil, to)) {
+		return
+	}
+	d.ComponentChanges = append(d.ComponentChanges, ComponentChange{
+		EntityId:  entityId,
+		Component: component,
+		Field:     field,
+		From:      fmt.Sprint(from),
+		To:        fmt.Sprint(to),
+	})
+}
+
+func joinField(field, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
@@ -0,0 +1,91 @@
+package ecsgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type DiffStats struct {
+	HP    int
+	Tags  []string
+	Items map[string]int
+	inner struct{ level int }
+}
+
+func init() {
+	RegisterComponent[DiffStats]("DiffStats")
+}
+
+func TestDiff(t *testing.T) {
+	r1 := NewRegistry()
+	var entities []EntityId
+	for i := 0; i < 4; i++ {
+		e := r1.CreateEntity()
+		entities = append(entities, e)
+		AddComponent(r1, e, SnapPosition{X: float32(i), Y: float32(i)})
+		AddComponent(r1, e, DiffStats{HP: 10, Tags: []string{"a", "b"}, Items: map[string]int{"gold": 1}})
+	}
+	assert.NoError(t, r1.Flush())
+
+	r2 := NewRegistry()
+	for i := 0; i < 4; i++ {
+		e := r2.CreateEntity()
+		AddComponent(r2, e, DiffStats{HP: 10, Tags: []string{"a", "b"}, Items: map[string]int{"gold": 1}})
+		AddComponent(r2, e, SnapPosition{X: float32(i), Y: float32(i)})
+	}
+	assert.NoError(t, r2.Flush())
+	assert.True(t, Diff(r1, r2).IsEmpty())
+	assert.Equal(t, "no difference", Diff(r1, r2).String())
+
+	r2.RemoveEntity(entities[0])
+	AddComponent(r2, entities[1], SnapVelocity{V: 1})
+	stats := DiffStats{HP: 5, Tags: []string{"a", "c"}, Items: map[string]int{"gold": 2}}
+	stats.inner.level = 3
+	AddComponent(r2, entities[2], stats)
+	created := r2.CreateEntity()
+	assert.NoError(t, r2.Flush())
+
+	d := Diff(r1, r2)
+	assert.Equal(t, []EntityId{created}, d.Created)
+	assert.Equal(t, []EntityId{entities[0]}, d.Removed)
+	assert.Equal(t, []ArcheTypeChange{{
+		EntityId: entities[1],
+		From:     []string{"DiffStats", "SnapPosition"},
+		To:       []string{"DiffStats", "SnapPosition", "SnapVelocity"},
+	}}, d.ArcheTypeChanges)
+	assert.Equal(t, []ComponentChange{
+		{EntityId: entities[2], Component: "DiffStats", Field: "HP", From: "10", To: "5"},
+		{EntityId: entities[2], Component: "DiffStats", Field: "Tags[1]", From: "b", To: "c"},
+		{EntityId: entities[2], Component: "DiffStats", Field: "Items[gold]", From: "1", To: "2"},
+		{EntityId: entities[2], Component: "DiffStats", Field: "inner.level", From: "0", To: "3"},
+	}, d.ComponentChanges)
+	assert.Contains(t, d.String(), "~ entity "+entities[2].String()+" DiffStats.HP: 10 -> 5")
+
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	r := NewRegistry()
+	e1 := r.CreateEntity()
+	AddComponent(r, e1, SnapPosition{X: 1, Grid: [2]int16{1, 2}})
+	e2 := r.CreateEntity()
+	AddComponent(r, e2, SnapTag{})
+	assert.NoError(t, r.Flush())
+	before, err := r.Snapshot()
+	assert.NoError(t, err)
+
+	assert.NoError(t, AddComponentImmediate(r, e1, SnapPosition{X: 1, Grid: [2]int16{1, 3}}))
+	assert.NoError(t, r.RemoveEntityImmediate(e2))
+	after, err := r.Snapshot()
+	assert.NoError(t, err)
+
+	d, err := DiffSnapshots(before, after)
+	assert.NoError(t, err)
+	assert.Equal(t, []EntityId{e2}, d.Removed)
+	assert.Equal(t, []ComponentChange{
+		{EntityId: e1, Component: "SnapPosition", Field: "Grid[1]", From: "2", To: "3"},
+	}, d.ComponentChanges)
+
+	_, err = DiffSnapshots(before, []byte("broken"))
+	assert.Error(t, err)
+}
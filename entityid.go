@@ -1,10 +1,88 @@
 package ecsgo
 
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
 type EntityId struct {
 	id      uint32
 	version uint32
 }
 
+// EntityIdBinarySize is size of EntityId in binary encoding
+const EntityIdBinarySize = 8
+
 func (e EntityId) NotNil() bool {
 	return e.id != 0 && e.version != 0
 }
+
+// Index returns index of entity, index is reused after entity is removed
+func (e EntityId) Index() uint32 {
+	return e.id
+}
+
+// Version returns version of entity, it is increased when index is reused
+func (e EntityId) Version() uint32 {
+	return e.version
+}
+
+// Uint64 encodes EntityId to uint64, version is upper 32 bits and index is lower 32 bits
+func (e EntityId) Uint64() uint64 {
+	return uint64(e.version)<<32 | uint64(e.id)
+}
+
+// EntityIdFromUint64 decodes EntityId from value of EntityId.Uint64
+func EntityIdFromUint64(v uint64) EntityId {
+	return EntityId{
+		id:      uint32(v),
+		version: uint32(v >> 32),
+	}
+}
+
+// String returns "index:version"
+func (e EntityId) String() string {
+	return strconv.FormatUint(uint64(e.id), 10) + ":" + strconv.FormatUint(uint64(e.version), 10)
+}
+
+func (e EntityId) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+func (e *EntityId) UnmarshalText(text []byte) error {
+	idStr, versionStr, found := strings.Cut(string(text), ":")
+	if !found {
+		return errors.Errorf("invalid EntityId %q", text)
+	}
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return errors.Wrapf(err, "invalid EntityId index %q", text)
+	}
+	version, err := strconv.ParseUint(versionStr, 10, 32)
+	if err != nil {
+		return errors.Wrapf(err, "invalid EntityId version %q", text)
+	}
+	e.id = uint32(id)
+	e.version = uint32(version)
+	return nil
+}
+
+// MarshalBinary encodes EntityId to 8 bytes little endian of EntityId.Uint64
+func (e EntityId) MarshalBinary() ([]byte, error) {
+	return e.AppendBinary(nil)
+}
+
+func (e EntityId) AppendBinary(b []byte) ([]byte, error) {
+	return binary.LittleEndian.AppendUint64(b, e.Uint64()), nil
+}
+
+func (e *EntityId) UnmarshalBinary(data []byte) error {
+	if len(data) != EntityIdBinarySize {
+		return errors.Errorf("invalid EntityId binary size %d", len(data))
+	}
+	*e = EntityIdFromUint64(binary.LittleEndian.Uint64(data))
+	return nil
+}
@@ -0,0 +1,53 @@
+package ecsgo
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntityIdEncoding(t *testing.T) {
+	e := EntityId{id: 123, version: 45}
+	assert.Equal(t, uint32(123), e.Index())
+	assert.Equal(t, uint32(45), e.Version())
+	assert.Equal(t, "123:45", e.String())
+
+	assert.Equal(t, uint64(45)<<32|123, e.Uint64())
+	assert.Equal(t, e, EntityIdFromUint64(e.Uint64()))
+
+	text, err := e.MarshalText()
+	assert.NoError(t, err)
+	var fromText EntityId
+	assert.NoError(t, fromText.UnmarshalText(text))
+	assert.Equal(t, e, fromText)
+
+	bin, err := e.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Len(t, bin, EntityIdBinarySize)
+	var fromBin EntityId
+	assert.NoError(t, fromBin.UnmarshalBinary(bin))
+	assert.Equal(t, e, fromBin)
+
+	// json uses text encoding, also as map key
+	type holder struct {
+		Id    EntityId
+		Links map[EntityId]int
+	}
+	data, err := json.Marshal(holder{Id: e, Links: map[EntityId]int{e: 1}})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"Id":"123:45","Links":{"123:45":1}}`, string(data))
+	var h holder
+	assert.NoError(t, json.Unmarshal(data, &h))
+	assert.Equal(t, e, h.Id)
+	assert.Equal(t, 1, h.Links[e])
+
+	var invalid EntityId
+	assert.Error(t, invalid.UnmarshalText([]byte("123")))
+	assert.Error(t, invalid.UnmarshalText([]byte("a:1")))
+	assert.Error(t, invalid.UnmarshalBinary([]byte{1, 2}))
+
+	var nilId EntityId
+	assert.False(t, nilId.NotNil())
+	assert.Equal(t, "0:0", nilId.String())
+}
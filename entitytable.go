@@ -0,0 +1,88 @@
+package ecsgo
+
+type entityRecord struct {
+	// version of current entity that uses this id
+	version uint32
+	alive   bool
+	// archeType and row that entity is located, archeType is nil if entity has no component
+	archeType *ArcheType
+	row       int
+}
+
+// entityTable is dense array of entity records indexed by entity id
+type entityTable struct {
+	records []entityRecord
+}
+
+func (t *entityTable) issue(entityId EntityId) {
+	for int(entityId.id) >= len(t.records) {
+		t.records = append(t.records, entityRecord{})
+	}
+	t.records[entityId.id] = entityRecord{
+		version: entityId.version,
+		alive:   true,
+	}
+}
+
+// get returns record if entity is alive and version is matched
+func (t *entityTable) get(entityId EntityId) *entityRecord {
+	if entityId.id == 0 || int(entityId.id) >= len(t.records) {
+		return nil
+	}
+	rec := &t.records[entityId.id]
+	if !rec.alive || rec.version != entityId.version {
+		return nil
+	}
+	return rec
+}
+
+func (t *entityTable) remove(entityId EntityId) {
+	rec := t.get(entityId)
+	if rec == nil {
+		return
+	}
+	rec.alive = false
+	rec.archeType = nil
+}
+
+// isStale returns true if entity id was issued before but it is removed or id is reused
+func (t *entityTable) isStale(entityId EntityId) bool {
+	if entityId.id == 0 || int(entityId.id) >= len(t.records) {
+		return false
+	}
+	rec := &t.records[entityId.id]
+	if entityId.version == 0 || entityId.version > rec.version {
+		return false
+	}
+	return !rec.alive || entityId.version < rec.version
+}
+
+// setLocation sets location of entity, record is issued if it is not issued yet
+func (t *entityTable) setLocation(entityId EntityId, a *ArcheType, row int) {
+	rec := t.get(entityId)
+	if rec == nil {
+		t.issue(entityId)
+		rec = &t.records[entityId.id]
+	}
+	rec.archeType = a
+	rec.row = row
+}
+
+func (t *entityTable) getArcheType(entityId EntityId) *ArcheType {
+	rec := t.get(entityId)
+	if rec == nil {
+		return nil
+	}
+	return rec.archeType
+}
+
+func (t *entityTable) reset() {
+	clear(t.records)
+	t.records = t.records[:0]
+}
+
+// setRemoved sets record of removed entity, it is used when entity table is restored
+func (t *entityTable) setRemoved(entityId EntityId) {
+	t.issue(entityId)
+	t.records[entityId.id].alive = false
+}
@@ -0,0 +1,121 @@
+package ecsgo
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrTickInProgress is returned when an operation that requires registry not ticking is called during Tick
+	ErrTickInProgress = errors.New("registry is ticking")
+	// ErrEntityNotFound is returned when entity is not created or already removed
+	ErrEntityNotFound = errors.New("entity not found")
+	// ErrStaleEntity is returned when entity is already removed, it wraps ErrEntityNotFound
+	// so errors.Is with ErrEntityNotFound is also true
+	ErrStaleEntity = errors.WithMessage(ErrEntityNotFound, "stale entity")
+	// ErrEntityAlreadyCreated is returned when create entity action is recorded twice for same entity
+	ErrEntityAlreadyCreated = errors.New("entity already created")
+	// ErrComponentMissing is returned when removing component that entity doesn't have
+	ErrComponentMissing = errors.New("component missing")
+	// ErrRollbackTooOld is returned when tick is older than kept snapshots
+	ErrRollbackTooOld = errors.New("tick is too old to rollback")
+	// ErrDesync is returned when world state hash is different from expected
+	ErrDesync = errors.New("world state desync")
+	// ErrUnknownBaseline is returned when delta packet is based on tick that is not kept
+	ErrUnknownBaseline = errors.New("unknown baseline tick")
+)
+
+// EntityError is error of entity operation with entity id and component type
+type EntityError struct {
+	Op            string
+	EntityId      EntityId
+	ComponentType reflect.Type
+	Err           error
+}
+
+func newEntityError(action entityAction, entityId EntityId, err error) *EntityError {
+	op, ty := action.describe()
+	return &EntityError{
+		Op:            op,
+		EntityId:      entityId,
+		ComponentType: ty,
+		Err:           err,
+	}
+}
+
+func (e *EntityError) Error() string {
+	if e.ComponentType != nil {
+		return fmt.Sprintf("%s entity %v component %v: %v", e.Op, e.EntityId, e.ComponentType, e.Err)
+	}
+	return fmt.Sprintf("%s entity %v: %v", e.Op, e.EntityId, e.Err)
+}
+
+func (e *EntityError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorPolicy decides what happens when an error occurs while processing deferred actions
+type ErrorPolicy int
+
+const (
+	// ErrorPolicyAbort stops the flush at the first error, remaining actions are processed on next flush
+	ErrorPolicyAbort ErrorPolicy = iota
+	// ErrorPolicyLog logs the error and continues
+	ErrorPolicyLog
+	// ErrorPolicyCollect continues and returns all errors as FlushErrors at the end of the flush, or at the end of Tick
+	// so systems still run when deferred actions before systems failed
+	ErrorPolicyCollect
+)
+
+// FlushErrors is returned when errors are collected by ErrorPolicyCollect
+type FlushErrors []error
+
+func (e FlushErrors) Error() string {
+	var sb strings.Builder
+	for i, err := range e {
+		if i > 0 {
+			sb.WriteString("; ")
+		}
+		sb.WriteString(err.Error())
+	}
+	return sb.String()
+}
+
+func (e FlushErrors) Unwrap() []error {
+	return e
+}
+
+type errorHandler struct {
+	policy    ErrorPolicy
+	collected FlushErrors
+}
+
+// handle returns error only if flush should be aborted
+func (h *errorHandler) handle(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch h.policy {
+	case ErrorPolicyLog:
+		log.Printf("ecsgo: %v", err)
+		return nil
+	case ErrorPolicyCollect:
+		h.collected = append(h.collected, err)
+		return nil
+	}
+	return err
+}
+
+// takeCollected returns collected errors and reset
+func (h *errorHandler) takeCollected() error {
+	if len(h.collected) == 0 {
+		return nil
+	}
+	errs := h.collected
+	h.collected = nil
+	return errs
+}
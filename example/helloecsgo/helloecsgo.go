@@ -81,6 +81,9 @@ func main() {
 	ecsgo.AddComponent(registry, entity, Velocity{20, 20})
 	ecsgo.AddComponent(registry, entity, EnemyTag{})
 
+	// Close stops workers that run systems, registry should be closed when it is not used anymore
+	defer registry.Close()
+
 	ctx := context.Background()
 	for i := 0; i < 10; i++ {
 		registry.Tick(time.Second, ctx)
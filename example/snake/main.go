@@ -33,9 +33,11 @@ func main() {
 }
 
 type EbitenGame struct {
-	registry   *ecsgo.Registry
-	renderInfo RenderInfo
-	ctx        context.Context
+	registry  *ecsgo.Registry
+	view      *ecsgo.WorldView
+	bestScore int
+	gameOver  bool
+	ctx       context.Context
 }
 
 func newGame() *EbitenGame {
@@ -48,6 +50,7 @@ func newGame() *EbitenGame {
 var GlobalGameState ecsgo.EntityId
 
 func (g *EbitenGame) Reset() {
+	g.gameOver = false
 	g.registry = ecsgo.NewRegistry()
 
 	sys1 := g.registry.AddSystem("directionSystem", 5, inputProcess)
@@ -90,10 +93,11 @@ func (g *EbitenGame) Reset() {
 	q = sys5.NewQuery()
 	ecsgo.AddReadonlyComponent[GameState](q)
 
-	sys6 := g.registry.AddSystem("setRenders", 0, g.SetRenders)
-	q = sys6.NewQuery()
-	ecsgo.AddReadWriteComponent[Position](q)
-	ecsgo.AddReadWriteComponent[Color](q)
+	// Draw reads components from view that is published at the end of every tick
+	g.view = g.registry.NewWorldView()
+	ecsgo.AddViewComponent[Position](g.view)
+	ecsgo.AddViewComponent[Color](g.view)
+	ecsgo.AddViewComponent[GameState](g.view)
 
 	GlobalGameState = g.registry.CreateEntity()
 	ecsgo.AddComponent[GameState](g.registry, GlobalGameState, GameState{
@@ -133,15 +137,37 @@ func (g *EbitenGame) Reset() {
 
 func (g *EbitenGame) Update() error {
 	g.registry.Tick(100*time.Millisecond, g.ctx)
+	if g.gameOver {
+		// registry can't be closed by its own system, so it is reset after tick
+		g.registry.Close()
+		g.Reset()
+	}
 	return nil
 }
 
 func (g *EbitenGame) Draw(screen *ebiten.Image) {
-	for _, v := range g.renderInfo.objs {
-		vector.DrawFilledRect(screen, float32(v.X*gridSize), float32(v.Y*gridSize), gridSize, gridSize, v.Color, false)
+	frame := g.view.Acquire()
+	if frame == nil {
+		return
+	}
+	defer frame.Release()
+
+	frame.Foreach(func(accessor *ecsgo.ArcheTypeAccessor) error {
+		pos := ecsgo.GetComponentByAccessor[Position](accessor)
+		col := ecsgo.GetComponentByAccessor[Color](accessor)
+		if pos == nil || col == nil {
+			return nil
+		}
+		vector.DrawFilledRect(screen, float32(pos.X*gridSize), float32(pos.Y*gridSize), gridSize, gridSize, col.Color, false)
+		return nil
+	})
+	var level, score int
+	if gameState := ecsgo.GetViewComponent[GameState](frame, GlobalGameState); gameState != nil {
+		level = gameState.Level
+		score = gameState.Score
 	}
 	ebitenutil.DebugPrint(screen, fmt.Sprintf("FPS: %0.2f Level: %d Score: %d Best Score: %d",
-		ebiten.ActualFPS(), g.renderInfo.level, g.renderInfo.score, g.renderInfo.bestScore))
+		ebiten.ActualFPS(), level, score, g.bestScore))
 }
 
 func (g *EbitenGame) Layout(outsideWidth, outsideHeight int) (int, int) {
@@ -194,19 +220,6 @@ type Head struct {
 type Apple struct{}
 type Body struct{}
 
-type RenderInfo struct {
-	objs      []RenderObj
-	score     int
-	level     int
-	bestScore int
-}
-
-type RenderObj struct {
-	X     int
-	Y     int
-	Color color.Color
-}
-
 // Process Input to set Dir
 func inputProcess(ctx *ecsgo.ExecutionContext) error {
 	qr := ctx.GetQueryResult(0)
@@ -391,36 +404,14 @@ func processCollsion(ctx *ecsgo.ExecutionContext) error {
 	return nil
 }
 
-func (g *EbitenGame) SetRenders(ctx *ecsgo.ExecutionContext) error {
-
-	g.renderInfo.objs = g.renderInfo.objs[:0]
-	qr := ctx.GetQueryResult(0)
-	qr.ForeachEntities(func(accessor *ecsgo.ArcheTypeAccessor) error {
-		pos := ecsgo.GetComponentByAccessor[Position](accessor)
-		col := ecsgo.GetComponentByAccessor[Color](accessor)
-
-		g.renderInfo.objs = append(g.renderInfo.objs, RenderObj{
-			X:     pos.X,
-			Y:     pos.Y,
-			Color: col.Color,
-		})
-		return nil
-	})
-	return nil
-}
-
 func (g *EbitenGame) checkGameOver(ctx *ecsgo.ExecutionContext) error {
 	qr := ctx.GetQueryResult(0)
 	qr.ForeachEntities(func(accessor *ecsgo.ArcheTypeAccessor) error {
 		gameState := ecsgo.GetComponentByAccessor[GameState](accessor)
 		if gameState.GameOver {
-			g.Reset()
-		} else {
-			g.renderInfo.score = gameState.Score
-			g.renderInfo.level = gameState.Level
-			if gameState.Score > g.renderInfo.bestScore {
-				g.renderInfo.bestScore = gameState.Score
-			}
+			g.gameOver = true
+		} else if gameState.Score > g.bestScore {
+			g.bestScore = gameState.Score
 		}
 		return nil
 	})
@@ -4,10 +4,10 @@ import (
 	"context"
 	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
-	"golang.org/x/sync/errgroup"
 )
 
 type executionGroup struct {
@@ -15,6 +15,16 @@ type executionGroup struct {
 
 	depRootNode *depTreeNode
 	dirty       bool
+	// sequential runs systems one by one on calling goroutine
+	sequential bool
+	// lastOrder is registration order of last added system
+	lastOrder int
+
+	// pool runs systems and jobs, it is made at first use
+	poolMx      sync.Mutex
+	pool        *workerPool
+	workerCount int
+	treeNodes   []*depTreeNode
 }
 
 func newExecutionGroup() *executionGroup {
@@ -23,6 +33,8 @@ func newExecutionGroup() *executionGroup {
 
 func (e *executionGroup) addSystem(sys *System) *executionGroup {
 	e.dirty = true
+	e.lastOrder++
+	sys.order = e.lastOrder
 	e.executeList = append(e.executeList, sys)
 	return e
 }
@@ -39,11 +51,16 @@ func (e *executionGroup) build() error {
 	}
 	e.dirty = false
 
+	// later system in list runs first when they are dependent,
+	// so earlier registered system runs first if priority and interest count are same
 	slices.SortFunc(e.executeList, func(a, b *System) int {
-		if a.GetPriority() == b.GetPriority() {
+		if a.GetPriority() != b.GetPriority() {
+			return a.GetPriority() - b.GetPriority()
+		}
+		if a.getInterestComponentCount() != b.getInterestComponentCount() {
 			return b.getInterestComponentCount() - a.getInterestComponentCount()
 		}
-		return a.GetPriority() - b.GetPriority()
+		return b.order - a.order
 	})
 
 	// make dependency graph
@@ -64,7 +81,29 @@ func (e *executionGroup) build() error {
 	// change dependency graph to dependency tree
 	var err error
 	e.depRootNode, err = changeToDependencyTree(nodes)
-	return errors.Errorf("failed to change to dependency tree: %v", err)
+	if err != nil {
+		return errors.Errorf("failed to change to dependency tree: %v", err)
+	}
+	e.treeNodes = collectTreeNodes(e.depRootNode)
+	return nil
+}
+
+// collectTreeNodes returns all nodes under root without duplication
+func collectTreeNodes(root *depTreeNode) []*depTreeNode {
+	visited := make(map[*depTreeNode]bool)
+	var nodes []*depTreeNode
+	var visit func(node *depTreeNode)
+	visit = func(node *depTreeNode) {
+		for _, edge := range node.edges {
+			if !visited[edge] {
+				visited[edge] = true
+				nodes = append(nodes, edge)
+				visit(edge)
+			}
+		}
+	}
+	visit(root)
+	return nodes
 }
 
 // dependency tree node
@@ -72,12 +111,12 @@ type depTreeNode struct {
 	sys *System
 
 	waitCount int
-	wg        sync.WaitGroup
+	// doneCount is number of done dependencies in current run
+	doneCount atomic.Int32
 	edges     []*depTreeNode
 }
 
 func (n *depTreeNode) addEdge(edge *depTreeNode) {
-	edge.wg.Add(1)
 	edge.waitCount++
 	n.edges = append(n.edges, edge)
 }
@@ -141,47 +180,179 @@ func (e *executionGroup) execute(deltaTime time.Duration, ctx context.Context) e
 	if err != nil {
 		return errors.Errorf("failed to build dependency tree %v", err)
 	}
-	errs, ctx := errgroup.WithContext(ctx)
+	if e.depRootNode == nil {
+		// no system
+		return nil
+	}
+	if e.sequential {
+		return runTreeSequential(e.depRootNode, deltaTime, ctx)
+	}
+	pool := e.getPool()
+	for _, node := range e.treeNodes {
+		node.doneCount.Store(0)
+	}
 
-	err = runTree(e.depRootNode, deltaTime, &sync.Map{}, errs, ctx)
-	if err != nil {
-		return errors.Errorf("failed to run dependency tree %v", err)
+	run := &treeRun{
+		pool:      pool,
+		deltaTime: deltaTime,
+		ctx:       ctx,
+	}
+	run.complete(e.depRootNode)
+	run.wg.Wait()
+	if run.err != nil {
+		return errors.Errorf("failed to run dependency tree %v", run.err)
 	}
-	return errs.Wait()
+	return nil
 }
 
-// runTree - run dependency tree parallely
-func runTree(node *depTreeNode, deltaTime time.Duration, visited *sync.Map, errs *errgroup.Group, ctx context.Context) error {
-	if ctx.Err() != nil {
-		return ctx.Err()
+// getPool returns worker pool, it is started if it is not
+func (e *executionGroup) getPool() *workerPool {
+	e.poolMx.Lock()
+	defer e.poolMx.Unlock()
+	if e.pool == nil {
+		e.pool = newWorkerPool(e.workerCount)
 	}
+	return e.pool
+}
 
-	node.wg.Wait()
-	// reset wait group count for next round
-	node.wg.Add(node.waitCount)
+// setWorkerCount sets number of workers, GOMAXPROCS if it is not positive
+func (e *executionGroup) setWorkerCount(n int) {
+	e.workerCount = n
+	e.close()
+}
 
-	var err error
-	if node.sys != nil {
-		err = node.sys.execute(deltaTime)
+// close stops workers, they are started again on next execute
+func (e *executionGroup) close() {
+	e.poolMx.Lock()
+	pool := e.pool
+	e.pool = nil
+	e.poolMx.Unlock()
+	if pool != nil {
+		pool.close()
 	}
+}
 
-	for _, edge := range node.edges {
-		edge.wg.Done()
+// treeRun runs dependency tree on worker pool, system is queued when all systems that it depends on are done
+type treeRun struct {
+	pool      *workerPool
+	deltaTime time.Duration
+	ctx       context.Context
+
+	wg     sync.WaitGroup
+	mx     sync.Mutex
+	err    error
+	failed atomic.Bool
+}
+
+func (run *treeRun) schedule(node *depTreeNode) {
+	run.wg.Add(1)
+	ok := run.pool.submit(func() {
+		defer run.wg.Done()
+		run.execute(node)
+	})
+	if !ok {
+		run.wg.Done()
+		run.fail(errors.New("worker pool is closed"))
 	}
+}
 
-	// shold return error after edge node waitGroup done for not starving edge
+func (run *treeRun) execute(node *depTreeNode) {
+	if run.failed.Load() {
+		return
+	}
+	if run.ctx.Err() != nil {
+		run.fail(run.ctx.Err())
+		return
+	}
+	err := node.sys.execute(run.deltaTime, run.ctx)
 	if err != nil {
-		return err
+		run.fail(err)
+		return
+	}
+	jobs := node.sys.takeJobs()
+	if len(jobs) == 0 {
+		run.complete(node)
+		return
+	}
+
+	// jobs of system access its components, so dependents are queued after they are done
+	run.wg.Add(1)
+	var remaining atomic.Int32
+	remaining.Store(int32(len(jobs)))
+	for _, h := range jobs {
+		h.onDone(func() {
+			if remaining.Add(-1) == 0 {
+				run.complete(node)
+				run.wg.Done()
+			}
+		})
 	}
+}
 
+// complete queues edges of node that all their dependencies are done
+func (run *treeRun) complete(node *depTreeNode) {
 	for _, edge := range node.edges {
-		_, loaded := visited.LoadOrStore(edge, true)
-		if !loaded {
-			e := edge
-			errs.Go(func() error {
-				return runTree(e, deltaTime, visited, errs, ctx)
-			})
+		if int(edge.doneCount.Add(1)) == edge.waitCount {
+			run.schedule(edge)
+		}
+	}
+}
+
+func (run *treeRun) fail(err error) {
+	run.mx.Lock()
+	defer run.mx.Unlock()
+	if run.err == nil {
+		run.err = err
+	}
+	run.failed.Store(true)
+}
+
+// runTreeSequential - run dependency tree on calling goroutine in stable topological order,
+// system that has higher priority runs first among runnable systems, then earlier registered one
+func runTreeSequential(root *depTreeNode, deltaTime time.Duration, ctx context.Context) error {
+	doneCount := make(map[*depTreeNode]int)
+	queue := []*depTreeNode{root}
+	for len(queue) > 0 {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		idx := 0
+		for i := 1; i < len(queue); i++ {
+			if runsBefore(queue[i].sys, queue[idx].sys) {
+				idx = i
+			}
+		}
+		node := queue[idx]
+		queue = slices.Delete(queue, idx, idx+1)
+		if node.sys != nil {
+			err := node.sys.execute(deltaTime, ctx)
+			if err != nil {
+				return err
+			}
+			// errors of jobs are returned at sync point of Tick
+			for _, h := range node.sys.takeJobs() {
+				node.sys.registry.Complete(h)
+			}
+		}
+		for _, edge := range node.edges {
+			doneCount[edge]++
+			if doneCount[edge] == edge.waitCount {
+				queue = append(queue, edge)
+			}
 		}
 	}
 	return nil
 }
+
+func runsBefore(a, b *System) bool {
+	if b == nil {
+		return false
+	}
+	if a == nil {
+		return true
+	}
+	if a.GetPriority() != b.GetPriority() {
+		return a.GetPriority() > b.GetPriority()
+	}
+	return a.order < b.order
+}
@@ -218,3 +218,33 @@ func TestExecutionGroup(t *testing.T) {
 		executed[i] = false
 	}
 }
+
+func TestSequentialExecution(t *testing.T) {
+	r := NewRegistry()
+	r.SetSequential(true)
+	e, err := r.CreateEntityImmediate()
+	assert.NoError(t, err)
+	assert.NoError(t, AddComponentImmediate(r, e, TestComponent1{}))
+	assert.NoError(t, AddComponentImmediate(r, e, TestComponent2{}))
+
+	// executed on calling goroutine, so no lock is needed
+	var executed []string
+	addSystem := func(name string, priority int, fn func(q *Query)) {
+		sys := r.AddSystem(name, priority, func(ctx *ExecutionContext) error {
+			executed = append(executed, name)
+			return nil
+		})
+		fn(sys.NewQuery())
+	}
+	addSystem("writeT1", 0, func(q *Query) { AddReadWriteComponent[TestComponent1](q) })
+	addSystem("writeT2", 0, func(q *Query) { AddReadWriteComponent[TestComponent2](q) })
+	addSystem("readT1", 0, func(q *Query) { AddReadonlyComponent[TestComponent1](q) })
+	addSystem("high", 10, func(q *Query) { AddReadWriteComponent[TestComponent1](q) })
+
+	for i := 0; i < 3; i++ {
+		executed = executed[:0]
+		assert.NoError(t, r.Tick(time.Millisecond, context.Background()))
+		// priority first, then registration order among runnable systems
+		assert.Equal(t, []string{"high", "writeT1", "writeT2", "readT1"}, executed)
+	}
+}
@@ -0,0 +1,251 @@
+package ecsgo
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"unsafe"
+)
+
+// HashReport is world state hash with breakdown per archetype
+type HashReport struct {
+	Hash       uint64
+	ArcheTypes []ArcheTypeHash
+}
+
+// ArcheTypeHash is hash of entities in archetype, Components are names of hashed components
+type ArcheTypeHash struct {
+	Components  []string
+	EntityCount int
+	Hash        uint64
+}
+
+// Hash returns stable checksum of all entities and component values.
+// It doesn't depend on map iteration order, archetype creation order and row order in archetype.
+func (r *Registry) Hash() uint64 {
+	return r.HashReport().Hash
+}
+
+// HashReport returns world state hash with hash per archetype that is sorted by component names
+func (r *Registry) HashReport() *HashReport {
+	report := &HashReport{}
+	var buf []byte
+	for _, a := range r.archeTypeList {
+		if a.getEntityCount() == 0 {
+			continue
+		}
+		var ah ArcheTypeHash
+		ah, buf = hashArcheType(a, buf)
+		report.ArcheTypes = append(report.ArcheTypes, ah)
+	}
+	// archetypes can have same hashed components when others are opted out, so hash is compared too
+	slices.SortFunc(report.ArcheTypes, func(a, b ArcheTypeHash) int {
+		if c := slices.Compare(a.Components, b.Components); c != 0 {
+			return c
+		}
+		if a.Hash < b.Hash {
+			return -1
+		} else if a.Hash > b.Hash {
+			return 1
+		}
+		return 0
+	})
+
+	h := fnv.New64a()
+	r.mx.Lock()
+	buf = binary.LittleEndian.AppendUint32(buf[:0], r.lastId)
+	for _, entityId := range r.tombstones {
+		buf = binary.LittleEndian.AppendUint64(buf, entityId.Uint64())
+	}
+	r.mx.Unlock()
+	h.Write(buf)
+
+	// entities without component
+	buf = buf[:0]
+	for id, rec := range r.entities.records {
+		if rec.alive && rec.archeType == nil {
+			buf = binary.LittleEndian.AppendUint64(buf, EntityId{id: uint32(id), version: rec.version}.Uint64())
+		}
+	}
+	h.Write(buf)
+
+	for _, ah := range report.ArcheTypes {
+		buf = buf[:0]
+		for _, name := range ah.Components {
+			buf = appendString(buf, name)
+		}
+		buf = binary.LittleEndian.AppendUint64(buf, ah.Hash)
+		h.Write(buf)
+	}
+	report.Hash = h.Sum64()
+	return report
+}
+
+type hashColumn struct {
+	name string
+	ct   *ComponentType
+	ty   reflect.Type
+}
+
+func hashArcheType(a *ArcheType, buf []byte) (ArcheTypeHash, []byte) {
+	var columns []hashColumn
+	for _, ty := range a.getComponentTypeList() {
+		ct := getComponentType(ty)
+		name := ty.String()
+		if ct != nil {
+			if !ct.isHashed() {
+				continue
+			}
+			name = ct.name
+		}
+		columns = append(columns, hashColumn{name: name, ct: ct, ty: ty})
+	}
+	slices.SortFunc(columns, func(a, b hashColumn) int {
+		return strings.Compare(a.name, b.name)
+	})
+
+	// hash by entity id order
+	rows := make([]int, len(a.enitityIds))
+	for i := range rows {
+		rows[i] = i
+	}
+	slices.SortFunc(rows, func(i, j int) int {
+		return int(a.enitityIds[i].id) - int(a.enitityIds[j].id)
+	})
+
+	h := fnv.New64a()
+	for _, row := range rows {
+		buf = binary.LittleEndian.AppendUint64(buf[:0], a.enitityIds[row].Uint64())
+		for _, col := range columns {
+			if col.ct != nil && col.ct.rawHashable {
+				buf = append(buf, col.ct.rowBytes(a, row)...)
+				continue
+			}
+			buf = appendHashValue(buf, getComponentValue(a, row, col.ty))
+		}
+		h.Write(buf)
+	}
+
+	ah := ArcheTypeHash{
+		EntityCount: len(rows),
+		Hash:        h.Sum64(),
+	}
+	for _, col := range columns {
+		ah.Components = append(ah.Components, col.name)
+	}
+	return ah, buf
+}
+
+// getComponentValue returns component value by reflect.Type, it works for unregistered component also
+func getComponentValue(a *ArcheType, idx int, ty reflect.Type) reflect.Value {
+	if ct := getComponentType(ty); ct != nil {
+		return reflect.ValueOf(ct.getValue(a, idx)).Elem()
+	}
+	v := a.components[ty]
+	if v == nil {
+		// not accessed yet, so it is zero value
+		return reflect.Zero(ty)
+	}
+	return reflect.ValueOf(v).Elem().FieldByName("arr").Index(idx)
+}
+
+// appendHashValue appends stable bytes of value by reflection, map is hashed regardless of iteration order
+func appendHashValue(buf []byte, v reflect.Value) []byte {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, 1)
+		}
+		return append(buf, 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.LittleEndian.AppendUint64(buf, uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return binary.LittleEndian.AppendUint64(buf, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(v.Float()))
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(real(c)))
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(imag(c)))
+	case reflect.String:
+		return appendString(buf, v.String())
+	case reflect.Array, reflect.Slice:
+		buf = binary.AppendUvarint(buf, uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			buf = appendHashValue(buf, v.Index(i))
+		}
+		return buf
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			buf = appendHashValue(buf, v.Field(i))
+		}
+		return buf
+	case reflect.Map:
+		// sum of entry hashes doesn't depend on iteration order
+		var sum uint64
+		var entry []byte
+		h := fnv.New64a()
+		iter := v.MapRange()
+		for iter.Next() {
+			entry = appendHashValue(entry[:0], iter.Key())
+			entry = appendHashValue(entry, iter.Value())
+			sum += hashBytes(h, entry)
+		}
+		buf = binary.AppendUvarint(buf, uint64(v.Len()))
+		return binary.LittleEndian.AppendUint64(buf, sum)
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return append(buf, 0)
+		}
+		buf = append(buf, 1)
+		if v.Kind() == reflect.Interface {
+			buf = appendString(buf, v.Elem().Type().String())
+		}
+		return appendHashValue(buf, v.Elem())
+	}
+	// chan, func and unsafe pointer are not hashed
+	return buf
+}
+
+func hashBytes(h hash.Hash64, data []byte) uint64 {
+	h.Reset()
+	h.Write(data)
+	return h.Sum64()
+}
+
+// isRawHashable returns true if memory of value can be hashed directly, it is plain old data without padding
+func isRawHashable(ty reflect.Type) bool {
+	switch ty.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	case reflect.Array:
+		return isRawHashable(ty.Elem())
+	case reflect.Struct:
+		var size uintptr
+		for i := 0; i < ty.NumField(); i++ {
+			f := ty.Field(i)
+			if !isRawHashable(f.Type) {
+				return false
+			}
+			size += f.Type.Size()
+		}
+		// no padding
+		return size == ty.Size()
+	}
+	return false
+}
+
+func rowBytes[T any](a *ArcheType, idx int) []byte {
+	var t T
+	size := int(unsafe.Sizeof(t))
+	if size == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(getArcheTypeComponentByIdx[T](a, idx))), size)
+}
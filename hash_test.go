@@ -0,0 +1,87 @@
+package ecsgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type HashPos struct {
+	X, Y int32
+}
+
+// HashPadded has padding so it is hashed by reflection
+type HashPadded struct {
+	A int8
+	B int64
+	M map[string]int
+}
+
+type HashDebug struct {
+	Frame int
+}
+
+type HashUnregistered struct {
+	S string
+}
+
+func init() {
+	RegisterComponent[HashPos]("HashPos")
+	RegisterComponent[HashPadded]("HashPadded")
+	RegisterComponent[HashDebug]("HashDebug").SetHashed(false)
+}
+
+func TestHash(t *testing.T) {
+	build := func(reverse bool, debugFrame int) (*Registry, []EntityId) {
+		r := NewRegistry()
+		var entities []EntityId
+		for i := 0; i < 10; i++ {
+			entities = append(entities, r.CreateEntity())
+		}
+		order := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+		if reverse {
+			order = []int{9, 8, 7, 6, 5, 4, 3, 2, 1, 0}
+		}
+		for _, i := range order {
+			e := entities[i]
+			if i%2 == 0 {
+				AddComponent(r, e, HashPadded{A: int8(i), B: int64(i), M: map[string]int{"a": i, "b": i * 2, "c": 3}})
+			}
+			AddComponent(r, e, HashPos{X: int32(i), Y: int32(-i)})
+			AddComponent(r, e, HashDebug{Frame: debugFrame})
+			if i == 3 {
+				AddComponent(r, e, HashUnregistered{S: "unregistered"})
+			}
+		}
+		assert.NoError(t, r.Flush())
+		return r, entities
+	}
+
+	r1, _ := build(false, 1)
+	r2, entities := build(true, 2)
+	// archetype creation order, row order and opted out component are not matter
+	assert.Equal(t, r1.Hash(), r2.Hash())
+	assert.Equal(t, r1.HashReport(), r2.HashReport())
+
+	report := r1.HashReport()
+	assert.Len(t, report.ArcheTypes, 3)
+	assert.Equal(t, []string{"HashPadded", "HashPos"}, report.ArcheTypes[0].Components)
+	assert.Equal(t, 5, report.ArcheTypes[0].EntityCount)
+
+	e := entities[5]
+	assert.NoError(t, AddComponentImmediate(r2, e, HashPos{X: 100}))
+	assert.NotEqual(t, r1.Hash(), r2.Hash())
+	report2 := r2.HashReport()
+	assert.Equal(t, report.ArcheTypes[0], report2.ArcheTypes[0])
+	assert.NotEqual(t, report.ArcheTypes[1], report2.ArcheTypes[1])
+	assert.NoError(t, AddComponentImmediate(r2, e, HashPos{X: 5, Y: -5}))
+	assert.Equal(t, r1.Hash(), r2.Hash())
+
+	// value of map and unregistered component
+	e0 := entities[1]
+	assert.NoError(t, AddComponentImmediate(r2, e0, HashPadded{M: map[string]int{"a": 1}}))
+	assert.NotEqual(t, r1.Hash(), r2.Hash())
+	e3 := entities[3]
+	assert.NoError(t, AddComponentImmediate(r1, e3, HashUnregistered{S: "changed"}))
+	assert.NotEqual(t, report, r1.HashReport())
+}
@@ -0,0 +1,207 @@
+package ecsgo
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// JobFunc is user defined work that runs on worker pool
+type JobFunc func(job *JobContext) error
+
+// JobContext is passed to job, structural changes should be recorded by Commands
+type JobContext struct {
+	registry *Registry
+	commands *CommandBuffer
+}
+
+// GetRegistry returns registry that job is scheduled on
+func (j *JobContext) GetRegistry() *Registry {
+	return j.registry
+}
+
+// Commands returns command buffer of job, it is played back at sync point of Tick in schedule order
+func (j *JobContext) Commands() *CommandBuffer {
+	if j.commands == nil {
+		j.commands = newCommandBuffer(j.registry)
+	}
+	return j.commands
+}
+
+const (
+	jobPending int32 = iota
+	jobRunning
+	jobDone
+)
+
+// JobHandle is handle of scheduled job, it is used as dependency of other jobs and to wait job by Complete
+type JobHandle struct {
+	fn   JobFunc
+	deps []*JobHandle
+	ctx  JobContext
+	pool *workerPool
+
+	state atomic.Int32
+	// remaining is number of dependencies that are not done
+	remaining atomic.Int32
+
+	mx         sync.Mutex
+	dependents []*JobHandle
+	// callbacks are called when job is done
+	callbacks []func()
+	done      chan struct{}
+	err       error
+}
+
+// jobScheduler keeps jobs that are not synced by Tick yet
+type jobScheduler struct {
+	mx   sync.Mutex
+	jobs []*JobHandle
+}
+
+// Schedule schedules job that runs on worker pool after all deps are done, it can be called in systems and jobs.
+// Job doesn't declare component access, so it should only touch data that is copied for it
+// or components that are accessed by system that schedules it by ExecutionContext.Schedule.
+// Job scheduled outside Tick is completed at start of next Tick before systems run,
+// registry should not be changed while it is running, use Complete to wait for it.
+// Job of which dependency failed is not executed and fails with error of dependency.
+// All jobs are completed at sync point of Tick after systems, commands of jobs are played back in schedule order.
+// In sequential mode, job is executed immediately on calling goroutine.
+func (r *Registry) Schedule(fn JobFunc, deps ...*JobHandle) *JobHandle {
+	h := &JobHandle{
+		fn:   fn,
+		deps: deps,
+		ctx:  JobContext{registry: r},
+		done: make(chan struct{}),
+	}
+	if !r.eg.sequential {
+		h.pool = r.eg.getPool()
+	}
+
+	r.jobs.mx.Lock()
+	r.jobs.jobs = append(r.jobs.jobs, h)
+	r.jobs.mx.Unlock()
+
+	// one more for registering, so job doesn't start until all dependencies are registered
+	h.remaining.Store(int32(len(deps)) + 1)
+	for _, dep := range deps {
+		dep.mx.Lock()
+		if dep.state.Load() == jobDone {
+			dep.mx.Unlock()
+			h.depDone()
+			continue
+		}
+		dep.dependents = append(dep.dependents, h)
+		dep.mx.Unlock()
+	}
+	h.depDone()
+	return h
+}
+
+// Schedule schedules job that belongs to system, systems that depend on the system start after its jobs are done.
+// Jobs scheduled by jobs don't belong to system, see Registry.Schedule
+func (c *ExecutionContext) Schedule(fn JobFunc, deps ...*JobHandle) *JobHandle {
+	h := c.registry.Schedule(fn, deps...)
+	if c.system != nil {
+		c.system.addJob(h)
+	}
+	return h
+}
+
+// Complete waits until job is done and returns its error, job that is not started yet runs on calling goroutine
+func (r *Registry) Complete(h *JobHandle) error {
+	if h.state.Load() == jobPending {
+		for _, dep := range h.deps {
+			r.Complete(dep)
+		}
+		h.run()
+	}
+	<-h.done
+	return h.err
+}
+
+// onDone calls fn when job is done, it is called immediately if job is already done
+func (h *JobHandle) onDone(fn func()) {
+	h.mx.Lock()
+	if h.state.Load() != jobDone {
+		h.callbacks = append(h.callbacks, fn)
+		h.mx.Unlock()
+		return
+	}
+	h.mx.Unlock()
+	fn()
+}
+
+// IsDone returns true if job is done
+func (h *JobHandle) IsDone() bool {
+	return h.state.Load() == jobDone
+}
+
+func (h *JobHandle) depDone() {
+	if h.remaining.Add(-1) != 0 {
+		return
+	}
+	if h.pool == nil || !h.pool.submit(h.run) {
+		h.run()
+	}
+}
+
+// run executes job if it is not started by other goroutine, all dependencies should be done
+func (h *JobHandle) run() {
+	if !h.state.CompareAndSwap(jobPending, jobRunning) {
+		return
+	}
+	for _, dep := range h.deps {
+		<-dep.done
+		if dep.err != nil {
+			h.err = errors.Wrap(dep.err, "dependency failed")
+			break
+		}
+	}
+	if h.err == nil {
+		h.err = h.fn(&h.ctx)
+	}
+
+	h.mx.Lock()
+	h.state.Store(jobDone)
+	close(h.done)
+	dependents := h.dependents
+	h.dependents = nil
+	callbacks := h.callbacks
+	h.callbacks = nil
+	h.mx.Unlock()
+
+	for _, dependent := range dependents {
+		dependent.depDone()
+	}
+	for _, fn := range callbacks {
+		fn()
+	}
+}
+
+// completeJobs completes all scheduled jobs and plays back their commands in schedule order,
+// it returns first error of jobs
+func (r *Registry) completeJobs() error {
+	var firstErr error
+	for {
+		r.jobs.mx.Lock()
+		jobs := r.jobs.jobs
+		r.jobs.jobs = nil
+		r.jobs.mx.Unlock()
+		if len(jobs) == 0 {
+			return firstErr
+		}
+
+		// jobs can schedule other jobs, they are completed on next loop
+		for _, h := range jobs {
+			err := r.Complete(h)
+			if err != nil && firstErr == nil {
+				firstErr = errors.Wrap(err, "job failed")
+			}
+			if h.ctx.commands != nil {
+				r.deferredActions.playback(h.ctx.commands)
+			}
+		}
+	}
+}
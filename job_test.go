@@ -0,0 +1,185 @@
+package ecsgo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJob(t *testing.T) {
+	r := NewRegistry()
+	defer r.Close()
+
+	var mx sync.Mutex
+	var order []string
+	job := func(name string) JobFunc {
+		return func(job *JobContext) error {
+			time.Sleep(time.Millisecond)
+			mx.Lock()
+			order = append(order, name)
+			mx.Unlock()
+			return nil
+		}
+	}
+
+	// diamond
+	a := r.Schedule(job("a"))
+	b := r.Schedule(job("b"), a)
+	c := r.Schedule(job("c"), a)
+	d := r.Schedule(job("d"), b, c)
+	assert.NoError(t, r.Complete(d))
+	assert.True(t, a.IsDone() && b.IsDone() && c.IsDone() && d.IsDone())
+	assert.Len(t, order, 4)
+	assert.Equal(t, "a", order[0])
+	assert.Equal(t, "d", order[3])
+
+	// failed dependency
+	errJob := errors.New("job error")
+	var executed atomic.Bool
+	failed := r.Schedule(func(job *JobContext) error { return errJob })
+	dependent := r.Schedule(func(job *JobContext) error {
+		executed.Store(true)
+		return nil
+	}, failed)
+	assert.ErrorIs(t, r.Complete(dependent), errJob)
+	assert.False(t, executed.Load())
+
+	// not completed jobs are synced by Tick and error is returned
+	err := r.Tick(time.Millisecond, context.Background())
+	assert.ErrorIs(t, err, errJob)
+	assert.NoError(t, r.Tick(time.Millisecond, context.Background()))
+}
+
+func TestJobInTick(t *testing.T) {
+	for _, sequential := range []bool{false, true} {
+		r := NewRegistry()
+		r.SetSequential(sequential)
+		for i := 1; i <= 100; i++ {
+			e := r.CreateEntity()
+			AddComponent(r, e, TestComponent1{X: i})
+		}
+		assert.NoError(t, r.Flush())
+
+		var sumJob *JobHandle
+		var sum atomic.Int64
+		producer := r.AddSystem("producer", 10, func(ctx *ExecutionContext) error {
+			var values []int
+			ctx.GetQueryResult(0).ForeachEntities(func(accessor *ArcheTypeAccessor) error {
+				values = append(values, GetComponentByAccessor[TestComponent1](accessor).X)
+				return nil
+			})
+			// jobs use copied values, so they can run after system
+			first := ctx.Schedule(func(job *JobContext) error {
+				for _, v := range values[:50] {
+					sum.Add(int64(v))
+				}
+				return nil
+			})
+			second := ctx.Schedule(func(job *JobContext) error {
+				for _, v := range values[50:] {
+					sum.Add(int64(v))
+				}
+				return nil
+			})
+			sumJob = ctx.Schedule(func(job *JobContext) error {
+				e := job.Commands().CreateEntity()
+				AddComponentCommand(job.Commands(), e, TestComponent2{V: float64(sum.Load())})
+				return nil
+			}, first, second)
+			return nil
+		})
+		AddReadonlyComponent[TestComponent1](producer.NewQuery())
+
+		var completedInSystem int64
+		consumer := r.AddSystem("consumer", 0, func(ctx *ExecutionContext) error {
+			// waits job of other system
+			err := ctx.GetResgiry().Complete(sumJob)
+			completedInSystem = sum.Load()
+			return err
+		})
+		// depends on producer
+		AddReadWriteComponent[TestComponent1](consumer.NewQuery())
+
+		assert.NoError(t, r.Tick(time.Millisecond, context.Background()))
+		assert.Equal(t, int64(5050), completedInSystem)
+
+		// command of job is applied in same tick
+		var found []float64
+		for _, a := range r.archeTypeList {
+			if HasArcheTypeComponent[TestComponent2](a) {
+				a.Foreach(func(accessor *ArcheTypeAccessor) error {
+					found = append(found, GetComponentByAccessor[TestComponent2](accessor).V)
+					return nil
+				})
+			}
+		}
+		assert.Equal(t, []float64{5050}, found)
+		r.Close()
+	}
+}
+
+func TestJobBelongsToSystem(t *testing.T) {
+	for _, sequential := range []bool{false, true} {
+		r := NewRegistry()
+		r.SetSequential(sequential)
+		r.SetWorkerCount(4)
+		for i := 1; i <= 10; i++ {
+			e := r.CreateEntity()
+			AddComponent(r, e, TestComponent1{X: i})
+		}
+		assert.NoError(t, r.Flush())
+
+		writer := r.AddSystem("writer", 10, func(ctx *ExecutionContext) error {
+			var values []*TestComponent1
+			ctx.GetQueryResult(0).ForeachEntities(func(accessor *ArcheTypeAccessor) error {
+				values = append(values, GetComponentByAccessor[TestComponent1](accessor))
+				return nil
+			})
+			// job writes components of system after system returns
+			ctx.Schedule(func(job *JobContext) error {
+				time.Sleep(5 * time.Millisecond)
+				for _, v := range values {
+					v.Y = v.X
+				}
+				return nil
+			})
+			return nil
+		})
+		AddReadWriteComponent[TestComponent1](writer.NewQuery())
+
+		var sum int
+		reader := r.AddSystem("reader", 0, func(ctx *ExecutionContext) error {
+			sum = 0
+			return ctx.GetQueryResult(0).ForeachEntities(func(accessor *ArcheTypeAccessor) error {
+				sum += GetComponentByAccessor[TestComponent1](accessor).Y
+				return nil
+			})
+		})
+		// depends on writer, so it starts after job of writer
+		AddReadonlyComponent[TestComponent1](reader.NewQuery())
+
+		assert.NoError(t, r.Tick(time.Millisecond, context.Background()))
+		assert.Equal(t, 55, sum)
+
+		// job scheduled outside Tick is completed before systems run
+		var outsideDone atomic.Bool
+		r.Schedule(func(job *JobContext) error {
+			time.Sleep(5 * time.Millisecond)
+			outsideDone.Store(true)
+			return nil
+		})
+		var doneInSystem bool
+		r.AddSystem("check", 0, func(ctx *ExecutionContext) error {
+			doneInSystem = outsideDone.Load()
+			return nil
+		})
+		assert.NoError(t, r.Tick(time.Millisecond, context.Background()))
+		assert.True(t, doneInSystem)
+		r.Close()
+	}
+}
@@ -0,0 +1,185 @@
+package ecsgo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// lockstepHashHistory is number of ticks that hashes are kept to compare with peers
+const lockstepHashHistory = 1024
+
+// LockstepInputFunc applies inputs of tick to registry before tick is executed, inputs are indexed by peer
+type LockstepInputFunc func(r *Registry, tick uint64, inputs []any) error
+
+// Lockstep runs registry with fixed delta time, tick is executed only when inputs of all peers for the tick arrived.
+// Hashes of world state are exchanged with peers to detect desync.
+// Registry is set to sequential mode so execution is deterministic.
+type Lockstep struct {
+	registry  *Registry
+	deltaTime time.Duration
+	inputFn   LockstepInputFunc
+	peerCount int
+
+	mx sync.Mutex
+	// next tick to be executed
+	tick   uint64
+	inputs map[uint64][]lockstepInput
+	hashes map[uint64]uint64
+	// hashes of peers that are received before tick is executed
+	peerHashes map[uint64][]peerHash
+}
+
+type lockstepInput struct {
+	input    any
+	received bool
+}
+
+type peerHash struct {
+	peer int
+	hash uint64
+}
+
+func NewLockstep(r *Registry, peerCount int, deltaTime time.Duration, inputFn LockstepInputFunc) *Lockstep {
+	r.SetSequential(true)
+	return &Lockstep{
+		registry:   r,
+		deltaTime:  deltaTime,
+		inputFn:    inputFn,
+		peerCount:  peerCount,
+		inputs:     make(map[uint64][]lockstepInput),
+		hashes:     make(map[uint64]uint64),
+		peerHashes: make(map[uint64][]peerHash),
+	}
+}
+
+// GetTick returns next tick to be executed
+func (l *Lockstep) GetTick() uint64 {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	return l.tick
+}
+
+// AddInput adds input of peer for tick, every peer should add one input for every tick even if it is nil
+func (l *Lockstep) AddInput(peer int, tick uint64, input any) error {
+	if peer < 0 || peer >= l.peerCount {
+		return errors.Errorf("invalid peer %d", peer)
+	}
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	if tick < l.tick {
+		return errors.Errorf("input of peer %d for tick %d is late, tick %d is already executed", peer, tick, l.tick-1)
+	}
+	inputs := l.inputs[tick]
+	if inputs == nil {
+		inputs = make([]lockstepInput, l.peerCount)
+		l.inputs[tick] = inputs
+	}
+	if inputs[peer].received {
+		return errors.Errorf("input of peer %d for tick %d is already added", peer, tick)
+	}
+	inputs[peer] = lockstepInput{input: input, received: true}
+	return nil
+}
+
+// IsReady returns true if inputs of all peers for next tick arrived
+func (l *Lockstep) IsReady() bool {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	return l.isReady()
+}
+
+func (l *Lockstep) isReady() bool {
+	inputs := l.inputs[l.tick]
+	if inputs == nil {
+		return l.peerCount == 0
+	}
+	for _, in := range inputs {
+		if !in.received {
+			return false
+		}
+	}
+	return true
+}
+
+// Tick executes next tick if it is ready and returns true if tick is executed.
+// It returns ErrDesync if hash of peer that is received before is different.
+func (l *Lockstep) Tick(ctx context.Context) (bool, error) {
+	l.mx.Lock()
+	if !l.isReady() {
+		l.mx.Unlock()
+		return false, nil
+	}
+	tick := l.tick
+	var inputs []any
+	for _, in := range l.inputs[tick] {
+		inputs = append(inputs, in.input)
+	}
+	delete(l.inputs, tick)
+	l.mx.Unlock()
+
+	if l.inputFn != nil {
+		err := l.inputFn(l.registry, tick, inputs)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to apply inputs of tick %d", tick)
+		}
+	}
+	err := l.registry.Tick(l.deltaTime, ctx)
+	if err != nil {
+		return false, err
+	}
+	hash := l.registry.Hash()
+
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.tick++
+	l.hashes[tick] = hash
+	if tick >= lockstepHashHistory {
+		delete(l.hashes, tick-lockstepHashHistory)
+	}
+	pending := l.peerHashes[tick]
+	delete(l.peerHashes, tick)
+	for _, ph := range pending {
+		err = checkPeerHash(ph.peer, tick, hash, ph.hash)
+		if err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// GetHash returns world state hash after tick is executed to send it to peers
+func (l *Lockstep) GetHash(tick uint64) (uint64, bool) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	hash, found := l.hashes[tick]
+	return hash, found
+}
+
+// CheckHash compares hash of peer with local one, it returns ErrDesync if they are different.
+// If tick is not executed yet, hash is compared when the tick is executed.
+func (l *Lockstep) CheckHash(peer int, tick uint64, hash uint64) error {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	if tick >= l.tick {
+		l.peerHashes[tick] = append(l.peerHashes[tick], peerHash{peer: peer, hash: hash})
+		return nil
+	}
+	localHash, found := l.hashes[tick]
+	if !found {
+		// too old to compare
+		return nil
+	}
+	return checkPeerHash(peer, tick, localHash, hash)
+}
+
+func checkPeerHash(peer int, tick uint64, localHash, hash uint64) error {
+	if localHash != hash {
+		return errors.Wrapf(ErrDesync, "tick %d hash %x of peer %d is different from local %x", tick, hash, peer, localHash)
+	}
+	return nil
+}
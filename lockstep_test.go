@@ -0,0 +1,82 @@
+package ecsgo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newLockstepPeer(t *testing.T) (*Lockstep, EntityId) {
+	r, e := newRollbackTestRegistry(t)
+	l := NewLockstep(r, 2, time.Millisecond, func(r *Registry, tick uint64, inputs []any) error {
+		for _, input := range inputs {
+			if input != nil {
+				in := input.(rollbackInput)
+				AddComponent(r, in.entity, RollbackVel{V: in.v})
+			}
+		}
+		return nil
+	})
+	return l, e
+}
+
+func TestLockstep(t *testing.T) {
+	ctx := context.Background()
+	peers := make([]*Lockstep, 2)
+	var e EntityId
+	peers[0], e = newLockstepPeer(t)
+	peers[1], _ = newLockstepPeer(t)
+
+	// input is sent to all peers
+	send := func(peer int, tick uint64, input any) {
+		for _, l := range peers {
+			assert.NoError(t, l.AddInput(peer, tick, input))
+		}
+	}
+
+	// waits until all inputs arrive
+	send(0, 0, rollbackInput{entity: e, v: 3})
+	assert.False(t, peers[0].IsReady())
+	ok, err := peers[0].Tick(ctx)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Error(t, peers[0].AddInput(0, 0, nil))
+	assert.Error(t, peers[0].AddInput(2, 0, nil))
+	send(1, 0, nil)
+
+	for tick := uint64(0); tick < 10; tick++ {
+		if tick > 0 {
+			send(0, tick, nil)
+			if tick == 5 {
+				send(1, tick, rollbackInput{entity: e, v: -1})
+			} else {
+				send(1, tick, nil)
+			}
+		}
+		for _, l := range peers {
+			assert.True(t, l.IsReady())
+			ok, err := l.Tick(ctx)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		}
+		hash0, found := peers[0].GetHash(tick)
+		assert.True(t, found)
+		assert.NoError(t, peers[1].CheckHash(0, tick, hash0))
+	}
+	assert.Equal(t, uint64(10), peers[1].GetTick())
+	// 3*5 - 1*5
+	assert.Equal(t, int64(10), GetEntityComponent[RollbackPos](peers[1].registry, e).X)
+	assert.Error(t, peers[0].AddInput(0, 3, nil))
+
+	// hash of peer that is ahead is checked when tick is executed
+	assert.NoError(t, peers[1].CheckHash(0, 10, 1234))
+	assert.NoError(t, peers[1].AddInput(0, 10, nil))
+	assert.NoError(t, peers[1].AddInput(1, 10, nil))
+	_, err = peers[1].Tick(ctx)
+	assert.ErrorIs(t, err, ErrDesync)
+
+	hash, _ := peers[1].GetHash(9)
+	assert.ErrorIs(t, peers[0].CheckHash(1, 9, hash+1), ErrDesync)
+}
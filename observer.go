@@ -1,6 +1,10 @@
 package ecsgo
 
-import "reflect"
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+)
 
 type ObserverContext struct {
 	registry          *Registry
@@ -12,10 +16,29 @@ type ObserverContext struct {
 
 type ObserverFunc func(ctx *ObserverContext) error
 
+// BatchObserverFunc is called once per flush with all entities that observer is interested in
+type BatchObserverFunc func(ctx *BatchObserverContext) error
+
+// ObserverMode decides when observer is called during deferred actions flush
+type ObserverMode int
+
+const (
+	// ObserverImmediate calls observer right after entity actions are applied
+	ObserverImmediate ObserverMode = iota
+	// ObserverDeferred calls observer after all entity actions of the flush are applied
+	ObserverDeferred
+)
+
 type Observer struct {
 	registry *Registry
 	name     string
 	fn       ObserverFunc
+	batchFn  BatchObserverFunc
+	priority int
+	mode     ObserverMode
+
+	// pending entities for batch observer
+	batch []*observerCall
 
 	addComponents    map[reflect.Type]bool
 	removeComponents map[reflect.Type]bool
@@ -29,10 +52,40 @@ func newObserver(registry *Registry, name string, fn ObserverFunc) *Observer {
 	}
 }
 
+func newBatchObserver(registry *Registry, name string, fn BatchObserverFunc) *Observer {
+	return &Observer{
+		registry: registry,
+		name:     name,
+		batchFn:  fn,
+	}
+}
+
+func (o *Observer) IsBatch() bool {
+	return o.batchFn != nil
+}
+
 func (o *Observer) GetName() string {
 	return o.name
 }
 
+func (o *Observer) GetPriority() int {
+	return o.priority
+}
+
+// SetPriority - observer with higher priority is called first, same priority observers are called in registration order
+func (o *Observer) SetPriority(priority int) {
+	o.priority = priority
+}
+
+func (o *Observer) GetMode() ObserverMode {
+	return o.mode
+}
+
+// SetMode - batch observer is always called at the end of flush regardless of mode
+func (o *Observer) SetMode(mode ObserverMode) {
+	o.mode = mode
+}
+
 func AddComponentToObserver[T any](o *Observer) {
 	var t T
 	if o.addComponents == nil {
@@ -49,14 +102,37 @@ func RemoveComponentFromObserver[T any](o *Observer) {
 	o.removeComponents[reflect.TypeOf(t)] = true
 }
 
-func (o *Observer) executeIfInterest(entityId EntityId, archeType *ArcheType, addedComponents, removedComponents []reflect.Type) error {
-	interested, interestedAdd, interestedRemove := o.interestedIn(addedComponents, removedComponents)
-	if interested {
-		return o.execute(entityId, archeType, interestedAdd, interestedRemove)
+type observerCall struct {
+	observer          *Observer
+	entityId          EntityId
+	archeType         *ArcheType
+	addedComponents   []reflect.Type
+	removedComponents []reflect.Type
+}
+
+func (c *observerCall) execute() error {
+	err := c.observer.execute(c.entityId, c.archeType, c.addedComponents, c.removedComponents)
+	if err != nil {
+		return errors.Wrapf(err, "observer %s failed on entity %v", c.observer.name, c.entityId)
 	}
 	return nil
 }
 
+// makeCallIfInterest returns nil if observer is not interested in changes
+func (o *Observer) makeCallIfInterest(entityId EntityId, archeType *ArcheType, addedComponents, removedComponents []reflect.Type) *observerCall {
+	interested, interestedAdd, interestedRemove := o.interestedIn(addedComponents, removedComponents)
+	if !interested {
+		return nil
+	}
+	return &observerCall{
+		observer:          o,
+		entityId:          entityId,
+		archeType:         archeType,
+		addedComponents:   interestedAdd,
+		removedComponents: interestedRemove,
+	}
+}
+
 func (o *Observer) interestedIn(addedComponents, removedComponents []reflect.Type) (interested bool, added []reflect.Type, removed []reflect.Type) {
 	for _, t := range addedComponents {
 		_, found := o.addComponents[t]
@@ -100,3 +176,75 @@ func (ctx *ObserverContext) CreateEntity() EntityId {
 func GetComponentObserver[T any](ctx *ObserverContext) *T {
 	return getArcheTypeComponent[T](ctx.archeType, ctx.entityId)
 }
+
+func (o *Observer) executeBatch() error {
+	if len(o.batch) == 0 {
+		return nil
+	}
+	calls := o.batch
+	o.batch = nil
+	err := o.batchFn(&BatchObserverContext{
+		registry: o.registry,
+		calls:    calls,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "batch observer %s failed on %d entities", o.name, len(calls))
+	}
+	return nil
+}
+
+type BatchObserverContext struct {
+	registry *Registry
+	calls    []*observerCall
+}
+
+func (ctx *BatchObserverContext) GetEntityCount() int {
+	return len(ctx.calls)
+}
+
+func (ctx *BatchObserverContext) GetEntityId(idx int) EntityId {
+	return ctx.calls[idx].entityId
+}
+
+// GetArcheType returns archetype of entity when it is observed, it is nil if entity has no component
+func (ctx *BatchObserverContext) GetArcheType(idx int) *ArcheType {
+	return ctx.calls[idx].archeType
+}
+
+func (ctx *BatchObserverContext) GetAddedComponents(idx int) []reflect.Type {
+	return ctx.calls[idx].addedComponents
+}
+
+func (ctx *BatchObserverContext) GetRemovedComponents(idx int) []reflect.Type {
+	return ctx.calls[idx].removedComponents
+}
+
+func (ctx *BatchObserverContext) CreateEntity() EntityId {
+	return ctx.registry.CreateEntity()
+}
+
+// ForeachEntities iterates entities that still exist in observed archetype
+func (ctx *BatchObserverContext) ForeachEntities(fn func(accessor *ArcheTypeAccessor) error) error {
+	for _, call := range ctx.calls {
+		if call.archeType == nil {
+			continue
+		}
+		accessor := call.archeType.GetAccessor(call.entityId)
+		if accessor == nil {
+			continue
+		}
+		err := fn(accessor)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func GetComponentBatchObserver[T any](ctx *BatchObserverContext, idx int) *T {
+	call := ctx.calls[idx]
+	if call.archeType == nil {
+		return nil
+	}
+	return getArcheTypeComponent[T](call.archeType, call.entityId)
+}
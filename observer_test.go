@@ -0,0 +1,170 @@
+package ecsgo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserverPriorityAndMode(t *testing.T) {
+	r := NewRegistry()
+
+	var called []string
+	o1 := r.AddObserver("low", func(ctx *ObserverContext) error {
+		called = append(called, "low")
+		return nil
+	})
+	AddComponentToObserver[TestComponent1](o1)
+
+	o2 := r.AddObserver("high", func(ctx *ObserverContext) error {
+		called = append(called, "high")
+		return nil
+	})
+	o2.SetPriority(10)
+	AddComponentToObserver[TestComponent1](o2)
+
+	o3 := r.AddObserver("deferred", func(ctx *ObserverContext) error {
+		called = append(called, "deferred")
+		return nil
+	})
+	o3.SetPriority(100)
+	o3.SetMode(ObserverDeferred)
+	AddComponentToObserver[TestComponent1](o3)
+
+	e1 := r.CreateEntity()
+	AddComponent(r, e1, TestComponent1{X: 1})
+	e2 := r.CreateEntity()
+	AddComponent(r, e2, TestComponent1{X: 2})
+
+	err := r.Tick(time.Second, context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"high", "low", "high", "low", "deferred", "deferred"}, called)
+}
+
+func TestObserverErrorPolicy(t *testing.T) {
+	errObserver := errors.New("observer error")
+
+	setup := func(policy ErrorPolicy) (*Registry, *int) {
+		r := NewRegistry()
+		r.SetErrorPolicy(policy)
+		count := new(int)
+		o := r.AddObserver("failing", func(ctx *ObserverContext) error {
+			*count++
+			return errObserver
+		})
+		AddComponentToObserver[TestComponent1](o)
+		for i := 0; i < 3; i++ {
+			e := r.CreateEntity()
+			AddComponent(r, e, TestComponent1{X: i})
+		}
+		return r, count
+	}
+
+	r, count := setup(ErrorPolicyAbort)
+	err := r.Tick(time.Second, context.Background())
+	assert.ErrorIs(t, err, errObserver)
+	assert.Equal(t, 1, *count)
+	// remained actions are not dropped, processed on next flushes
+	for i := 0; i < 2; i++ {
+		err = r.Tick(time.Second, context.Background())
+		assert.ErrorIs(t, err, errObserver)
+	}
+	assert.Equal(t, 3, *count)
+	assert.NoError(t, r.Tick(time.Second, context.Background()))
+
+	r, count = setup(ErrorPolicyLog)
+	err = r.Tick(time.Second, context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, *count)
+
+	r, count = setup(ErrorPolicyCollect)
+	err = r.Tick(time.Second, context.Background())
+	assert.ErrorIs(t, err, errObserver)
+	var flushErrs FlushErrors
+	assert.ErrorAs(t, err, &flushErrs)
+	assert.Len(t, flushErrs, 3)
+	assert.Equal(t, 3, *count)
+}
+
+func TestBatchObserver(t *testing.T) {
+	r := NewRegistry()
+
+	var batchCount int
+	var entities []EntityId
+	var sum int
+	o := r.AddBatchObserver("batch", func(ctx *BatchObserverContext) error {
+		batchCount++
+		for i := 0; i < ctx.GetEntityCount(); i++ {
+			entities = append(entities, ctx.GetEntityId(i))
+			assert.Len(t, ctx.GetAddedComponents(i), 1)
+		}
+		return ctx.ForeachEntities(func(accessor *ArcheTypeAccessor) error {
+			sum += GetComponentByAccessor[TestComponent1](accessor).X
+			return nil
+		})
+	})
+	AddComponentToObserver[TestComponent1](o)
+
+	var created []EntityId
+	for i := 1; i <= 100; i++ {
+		e := r.CreateEntity()
+		created = append(created, e)
+		AddComponent(r, e, TestComponent1{X: i})
+	}
+
+	err := r.Tick(time.Second, context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, batchCount)
+	assert.ElementsMatch(t, created, entities)
+	assert.Equal(t, 5050, sum)
+
+	// not interested
+	e := r.CreateEntity()
+	AddComponent(r, e, TestComponent2{V: 1})
+	err = r.Tick(time.Second, context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, batchCount)
+}
+
+func TestObserverRemovedComponentsOrder(t *testing.T) {
+	r := NewRegistry()
+	var removed [][]reflect.Type
+	o := r.AddBatchObserver("removed", func(ctx *BatchObserverContext) error {
+		for i := 0; i < ctx.GetEntityCount(); i++ {
+			removed = append(removed, ctx.GetRemovedComponents(i))
+		}
+		return nil
+	})
+	RemoveComponentFromObserver[TestComponent1](o)
+	RemoveComponentFromObserver[TestComponent2](o)
+	RemoveComponentFromObserver[TestComponent3](o)
+
+	var entities []EntityId
+	for i := 0; i < 20; i++ {
+		e := r.CreateEntity()
+		AddComponent(r, e, TestComponent3{})
+		AddComponent(r, e, TestComponent1{})
+		AddComponent(r, e, TestComponent2{})
+		entities = append(entities, e)
+	}
+	assert.NoError(t, r.Flush())
+	for _, e := range entities {
+		r.RemoveEntity(e)
+	}
+	assert.NoError(t, r.Flush())
+
+	// removed components are sorted by type name regardless of map order
+	expected := []reflect.Type{
+		reflect.TypeOf(TestComponent1{}),
+		reflect.TypeOf(TestComponent2{}),
+		reflect.TypeOf(TestComponent3{}),
+	}
+	assert.Len(t, removed, len(entities))
+	for _, types := range removed {
+		assert.Equal(t, expected, types)
+	}
+}
@@ -0,0 +1,142 @@
+package ecsgo
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const defaultParallelChunkSize = 256
+
+// ParallelOptions is options of QueryResult.ParallelForeach
+type ParallelOptions struct {
+	// ChunkSize is max number of rows in a chunk, default is 256
+	ChunkSize int
+}
+
+// ParallelChunk is range of rows in an archetype that is processed on one goroutine
+type ParallelChunk struct {
+	index     int
+	archeType *ArcheType
+	start     int
+	end       int
+	registry  *Registry
+	commands  *CommandBuffer
+	err       error
+}
+
+// GetIndex returns index of chunk, chunks are numbered in archetype and row order
+func (c *ParallelChunk) GetIndex() int {
+	return c.index
+}
+
+// Commands returns command buffer of chunk, structural changes should be recorded only by this buffer.
+// Buffers of chunks are moved to system command buffer in chunk order, so result is deterministic
+func (c *ParallelChunk) Commands() *CommandBuffer {
+	if c.commands == nil {
+		c.commands = newCommandBuffer(c.registry)
+	}
+	return c.commands
+}
+
+func (c *ParallelChunk) run(fn func(chunk *ParallelChunk, accessor *ArcheTypeAccessor) error) {
+	for row := c.start; row < c.end; row++ {
+		err := fn(c, c.archeType.getAceessorByIdx(row))
+		if err != nil {
+			c.err = err
+			return
+		}
+	}
+}
+
+// ParallelForeach splits matched archetypes into chunks of rows and processes them on worker pool.
+// Chunk boundaries only depend on archetypes and ChunkSize. fn is called concurrently for different chunks,
+// so it should only touch components of given accessor and make structural changes by chunk.Commands().
+// Error of lowest failed chunk is returned and chunks that are not started yet are skipped after error,
+// commands of chunks are discarded then.
+func (qr *QueryResult) ParallelForeach(fn func(chunk *ParallelChunk, accessor *ArcheTypeAccessor) error, opts ParallelOptions) error {
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultParallelChunkSize
+	}
+	var registry *Registry
+	if qr.ctx != nil {
+		registry = qr.ctx.registry
+	}
+
+	var chunks []*ParallelChunk
+	for _, a := range qr.archeTypeList {
+		for start := 0; start < a.getEntityCount(); start += chunkSize {
+			chunks = append(chunks, &ParallelChunk{
+				index:     len(chunks),
+				archeType: a,
+				start:     start,
+				end:       min(start+chunkSize, a.getEntityCount()),
+				registry:  registry,
+			})
+		}
+	}
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	var pool *workerPool
+	if registry != nil && !registry.eg.sequential {
+		pool = registry.eg.getPool()
+	}
+	runParallelChunks(pool, chunks, fn)
+
+	for _, chunk := range chunks {
+		if chunk.err != nil {
+			// commands of failed call are not played back
+			for _, c := range chunks {
+				if c.commands != nil {
+					c.commands.Discard()
+				}
+			}
+			return chunk.err
+		}
+	}
+	if qr.ctx != nil && qr.ctx.system != nil {
+		for _, chunk := range chunks {
+			if chunk.commands != nil && chunk.commands.Len() > 0 {
+				qr.ctx.Commands().appendBuffer(chunk.commands)
+			}
+		}
+	}
+	return nil
+}
+
+// runParallelChunks runs chunks on pool and calling goroutine.
+// Calling goroutine also takes chunks, so it doesn't wait for workers that are blocked by other systems
+func runParallelChunks(pool *workerPool, chunks []*ParallelChunk, fn func(chunk *ParallelChunk, accessor *ArcheTypeAccessor) error) {
+	var next atomic.Int32
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	wg.Add(len(chunks))
+
+	work := func() {
+		for {
+			idx := int(next.Add(1)) - 1
+			if idx >= len(chunks) {
+				return
+			}
+			chunk := chunks[idx]
+			if !failed.Load() {
+				chunk.run(fn)
+				if chunk.err != nil {
+					failed.Store(true)
+				}
+			}
+			wg.Done()
+		}
+	}
+
+	if pool != nil {
+		helpers := min(pool.size, len(chunks)-1)
+		for i := 0; i < helpers; i++ {
+			pool.submit(work)
+		}
+	}
+	work()
+	wg.Wait()
+}
@@ -0,0 +1,94 @@
+package ecsgo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParallelForeach(t *testing.T) {
+	r := NewRegistry()
+	r.SetWorkerCount(4)
+	defer r.Close()
+	for i := 0; i < 1000; i++ {
+		e := r.CreateEntity()
+		AddComponent(r, e, TestComponent1{X: i})
+		if i%2 == 0 {
+			// another archetype
+			AddComponent(r, e, TestComponent2{})
+		}
+	}
+	assert.NoError(t, r.Flush())
+
+	var mx sync.Mutex
+	chunkRanges := make(map[int][2]int)
+	var chunkErr error
+	var created []EntityId
+	sys := r.AddSystem("parallel", 0, func(ctx *ExecutionContext) error {
+		return ctx.GetQueryResult(0).ParallelForeach(func(chunk *ParallelChunk, accessor *ArcheTypeAccessor) error {
+			mx.Lock()
+			rng, found := chunkRanges[chunk.GetIndex()]
+			if !found {
+				rng = [2]int{accessor.idx, accessor.idx}
+			}
+			rng[1] = accessor.idx
+			chunkRanges[chunk.GetIndex()] = rng
+			mx.Unlock()
+
+			c := GetComponentByAccessor[TestComponent1](accessor)
+			c.Y++
+			if c.X%10 == 0 {
+				chunk.Commands().RemoveEntity(accessor.GetEntityId())
+			}
+			if chunkErr != nil {
+				mx.Lock()
+				created = append(created, chunk.Commands().CreateEntity())
+				mx.Unlock()
+				if c.X == 999 {
+					return chunkErr
+				}
+			}
+			return nil
+		}, ParallelOptions{ChunkSize: 64})
+	})
+	AddReadWriteComponent[TestComponent1](sys.NewQuery())
+
+	assert.NoError(t, r.Tick(time.Millisecond, context.Background()))
+	// 500 rows in each archetype
+	assert.Len(t, chunkRanges, 16)
+	assert.Equal(t, [2]int{0, 63}, chunkRanges[0])
+	assert.Equal(t, [2]int{448, 499}, chunkRanges[7])
+	assert.Equal(t, [2]int{0, 63}, chunkRanges[8])
+
+	var count, sumY int
+	q := &Query{}
+	AddReadonlyComponent[TestComponent1](q)
+	for _, a := range r.archeTypeList {
+		if q.addArcheTypeIfInterest(a) {
+			a.Foreach(func(accessor *ArcheTypeAccessor) error {
+				count++
+				sumY += GetComponentByAccessor[TestComponent1](accessor).Y
+				return nil
+			})
+		}
+	}
+	assert.Equal(t, 900, count)
+	assert.Equal(t, 900, sumY)
+
+	chunkErr = errors.New("chunk error")
+	assert.ErrorContains(t, r.Tick(time.Millisecond, context.Background()), "chunk error")
+	// ids issued by chunks of failed call are released
+	assert.NotEmpty(t, created)
+	for _, e := range created {
+		assert.False(t, r.IsActiveEntity(e), "entity %v", e)
+	}
+
+	// sequential mode runs chunks on calling goroutine
+	chunkErr = nil
+	r.SetSequential(true)
+	assert.NoError(t, r.Tick(time.Millisecond, context.Background()))
+}
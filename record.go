@@ -0,0 +1,292 @@
+package ecsgo
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const recordFormatVersion = 1
+
+const (
+	recordOpCreateEntity    = "createEntity"
+	recordOpReserveEntity   = "reserveEntity"
+	recordOpReleaseEntity   = "releaseEntity"
+	recordOpRemoveEntity    = "removeEntity"
+	recordOpAddComponent    = "addComponent"
+	recordOpRemoveComponent = "removeComponent"
+	recordOpFlush           = "flush"
+	recordOpTick            = "tick"
+)
+
+type recordHeader struct {
+	Version int `json:"version"`
+}
+
+// recordEvent is one line of recorded file
+type recordEvent struct {
+	Op        string          `json:"op"`
+	EntityId  *EntityId       `json:"entity,omitempty"`
+	Component string          `json:"component,omitempty"`
+	Value     json.RawMessage `json:"value,omitempty"`
+	Tick      uint64          `json:"tick,omitempty"`
+	DeltaTime time.Duration   `json:"deltaTime,omitempty"`
+}
+
+// componentValueAction is entity action that has component value
+type componentValueAction interface {
+	componentValue() any
+}
+
+// Recorder writes everything that mutates registry out of systems with delta time of each tick as JSON lines.
+// Mutations made by systems and observers during Tick or Flush are not recorded, they are reproduced by replay.
+// Component types should be registered by RegisterComponent.
+type Recorder struct {
+	mx   sync.Mutex
+	enc  *json.Encoder
+	tick uint64
+	err  error
+}
+
+func NewRecorder(w io.Writer) *Recorder {
+	rec := &Recorder{
+		enc: json.NewEncoder(w),
+	}
+	rec.err = rec.enc.Encode(&recordHeader{Version: recordFormatVersion})
+	return rec
+}
+
+// SetRecorder starts recording to rec, recording should be started on empty registry to be replayed.
+// nil stops recording
+func (r *Registry) SetRecorder(rec *Recorder) {
+	r.recorder = rec
+}
+
+// Err returns first error while recording, nothing is written after error
+func (rec *Recorder) Err() error {
+	rec.mx.Lock()
+	defer rec.mx.Unlock()
+	return rec.err
+}
+
+func (rec *Recorder) write(event *recordEvent) {
+	rec.mx.Lock()
+	defer rec.mx.Unlock()
+	if rec.err != nil {
+		return
+	}
+	rec.err = rec.enc.Encode(event)
+}
+
+func (rec *Recorder) fail(err error) {
+	rec.mx.Lock()
+	defer rec.mx.Unlock()
+	if rec.err == nil {
+		rec.err = err
+	}
+}
+
+func (rec *Recorder) recordAction(entityId EntityId, action entityAction) {
+	event := &recordEvent{EntityId: &entityId}
+	switch action.(type) {
+	case *createEntityAction:
+		event.Op = recordOpCreateEntity
+	case *removeEntityAction:
+		event.Op = recordOpRemoveEntity
+	default:
+		_, ty := action.describe()
+		ct := getComponentType(ty)
+		if ct == nil {
+			rec.fail(errors.Errorf("component %v is not registered", ty))
+			return
+		}
+		event.Component = ct.name
+		event.Op = recordOpRemoveComponent
+		if a, ok := action.(componentValueAction); ok {
+			event.Op = recordOpAddComponent
+			data, err := json.Marshal(a.componentValue())
+			if err != nil {
+				rec.fail(errors.Wrapf(err, "failed to marshal component %s of entity %v", ct.name, entityId))
+				return
+			}
+			event.Value = data
+		}
+	}
+	rec.write(event)
+}
+
+// recordReserve writes id that is issued by command buffer, its createEntity is written when buffer is submitted
+func (rec *Recorder) recordReserve(entityId EntityId) {
+	rec.write(&recordEvent{Op: recordOpReserveEntity, EntityId: &entityId})
+}
+
+// recordRelease writes id that is released by discarded command buffer without creating entity
+func (rec *Recorder) recordRelease(entityId EntityId) {
+	rec.write(&recordEvent{Op: recordOpReleaseEntity, EntityId: &entityId})
+}
+
+func (rec *Recorder) recordFlush() {
+	rec.write(&recordEvent{Op: recordOpFlush})
+}
+
+func (rec *Recorder) recordTick(deltaTime time.Duration) {
+	rec.mx.Lock()
+	tick := rec.tick
+	rec.tick++
+	rec.mx.Unlock()
+	rec.write(&recordEvent{Op: recordOpTick, Tick: tick, DeltaTime: deltaTime})
+}
+
+// Replayer replays recorded file into fresh registry.
+// Systems and observers should be added to registry same as recorded one before replay.
+type Replayer struct {
+	registry *Registry
+	dec      *json.Decoder
+	// number of executed ticks
+	tick uint64
+	done bool
+	// reserved has ids that are issued by command buffer and not created yet
+	reserved map[EntityId]bool
+}
+
+func NewReplayer(r *Registry, rd io.Reader) (*Replayer, error) {
+	dec := json.NewDecoder(rd)
+	var header recordHeader
+	err := dec.Decode(&header)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode record header")
+	}
+	if header.Version != recordFormatVersion {
+		return nil, errors.Errorf("unsupported record format version %d", header.Version)
+	}
+	return &Replayer{
+		registry: r,
+		dec:      dec,
+		reserved: make(map[EntityId]bool),
+	}, nil
+}
+
+// GetTick returns number of executed ticks, it is next tick to be executed
+func (rp *Replayer) GetTick() uint64 {
+	return rp.tick
+}
+
+// IsDone returns true if all recorded events are replayed
+func (rp *Replayer) IsDone() bool {
+	return rp.done
+}
+
+// Step replays events until next tick is executed, it returns false if there is no more events
+func (rp *Replayer) Step(ctx context.Context) (bool, error) {
+	for !rp.done {
+		var event recordEvent
+		err := rp.dec.Decode(&event)
+		if err == io.EOF {
+			rp.done = true
+			break
+		}
+		if err != nil {
+			return false, errors.Wrap(err, "failed to decode record event")
+		}
+		if event.Op == recordOpTick {
+			err = rp.registry.Tick(event.DeltaTime, ctx)
+			rp.tick++
+			if err != nil {
+				return true, errors.Wrapf(err, "tick %d", event.Tick)
+			}
+			return true, nil
+		}
+		err = rp.apply(&event)
+		if err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// RunUntil replays until tick is going to be executed, so state is same as before recorded tick
+func (rp *Replayer) RunUntil(tick uint64, ctx context.Context) error {
+	for rp.tick < tick {
+		ok, err := rp.Step(ctx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Run replays all events
+func (rp *Replayer) Run(ctx context.Context) error {
+	for {
+		ok, err := rp.Step(ctx)
+		if err != nil || !ok {
+			return err
+		}
+	}
+}
+
+func (rp *Replayer) apply(event *recordEvent) error {
+	r := rp.registry
+	if event.Op == recordOpFlush {
+		return r.Flush()
+	}
+	if event.EntityId == nil {
+		return errors.Errorf("%s event has no entity", event.Op)
+	}
+	entityId := *event.EntityId
+
+	switch event.Op {
+	case recordOpReserveEntity:
+		reserved := r.issueEntityId()
+		if reserved != entityId {
+			return errors.Wrapf(ErrDesync, "reserved entity %v but recorded %v", reserved, entityId)
+		}
+		rp.reserved[entityId] = true
+		return nil
+	case recordOpReleaseEntity:
+		if !rp.reserved[entityId] {
+			return errors.Wrapf(ErrDesync, "released entity %v is not reserved", entityId)
+		}
+		delete(rp.reserved, entityId)
+		r.releaseEntityIds([]EntityId{entityId})
+		return nil
+	case recordOpCreateEntity:
+		if rp.reserved[entityId] {
+			delete(rp.reserved, entityId)
+			r.deferredActions.createEntity(entityId)
+			return nil
+		}
+		created := r.CreateEntity()
+		if created != entityId {
+			return errors.Wrapf(ErrDesync, "created entity %v but recorded %v", created, entityId)
+		}
+		return nil
+	case recordOpRemoveEntity:
+		r.RemoveEntity(entityId)
+		return nil
+	case recordOpAddComponent, recordOpRemoveComponent:
+		ct := getComponentTypeByName(event.Component)
+		if ct == nil {
+			return errors.Errorf("component %s is not registered", event.Component)
+		}
+		if event.Op == recordOpRemoveComponent {
+			ct.removeComponent(r, entityId)
+			return nil
+		}
+		ptr := ct.newValue()
+		err := json.Unmarshal(event.Value, ptr)
+		if err != nil {
+			return errors.Wrapf(err, "failed to unmarshal component %s of entity %v", ct.name, entityId)
+		}
+		ct.addComponent(r, entityId, ptr)
+		return nil
+	}
+	return errors.Errorf("unknown record event %s", event.Op)
+}
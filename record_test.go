@@ -0,0 +1,213 @@
+package ecsgo
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newRecordTestRegistry() *Registry {
+	r := NewRegistry()
+	sys := r.AddSystem("move", 0, func(ctx *ExecutionContext) error {
+		return ctx.GetQueryResult(0).ForeachEntities(func(accessor *ArcheTypeAccessor) error {
+			pos := GetComponentByAccessor[RollbackPos](accessor)
+			vel := GetComponentByAccessor[RollbackVel](accessor)
+			pos.X += vel.V * int64(ctx.GetDeltaTime()/time.Millisecond)
+			// system mutation is not recorded but reproduced
+			if pos.X > 100 {
+				ctx.GetResgiry().RemoveEntity(accessor.GetEntityId())
+			}
+			return nil
+		})
+	})
+	q := sys.NewQuery()
+	AddReadWriteComponent[RollbackPos](q)
+	AddReadonlyComponent[RollbackVel](q)
+	return r
+}
+
+func TestRecordReplay(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+
+	r := newRecordTestRegistry()
+	rec := NewRecorder(&buf)
+	r.SetRecorder(rec)
+
+	var hashes []uint64
+	e1, err := r.CreateEntityImmediate()
+	assert.NoError(t, err)
+	AddComponent(r, e1, RollbackPos{})
+	AddComponent(r, e1, RollbackVel{V: 1})
+	for i := 0; i < 10; i++ {
+		if i == 3 {
+			e := r.CreateEntity()
+			AddComponent(r, e, RollbackPos{X: 50})
+			AddComponent(r, e, RollbackVel{V: 2})
+		}
+		if i == 5 {
+			RemoveComponent[RollbackVel](r, e1)
+		}
+		assert.NoError(t, r.Tick(time.Duration(i+1)*time.Millisecond, ctx))
+		hashes = append(hashes, r.Hash())
+	}
+	r.SetRecorder(nil)
+	assert.NoError(t, rec.Err())
+	// not recorded after stopped
+	r.CreateEntity()
+
+	// replay all
+	replayed := newRecordTestRegistry()
+	rp, err := NewReplayer(replayed, bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	assert.NoError(t, rp.Run(ctx))
+	assert.True(t, rp.IsDone())
+	assert.Equal(t, uint64(10), rp.GetTick())
+	assert.Equal(t, hashes[9], replayed.Hash())
+
+	// stop at tick
+	replayed = newRecordTestRegistry()
+	rp, err = NewReplayer(replayed, bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	assert.NoError(t, rp.RunUntil(4, ctx))
+	assert.Equal(t, uint64(4), rp.GetTick())
+	assert.Equal(t, hashes[3], replayed.Hash())
+	ok, err := rp.Step(ctx)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, hashes[4], replayed.Hash())
+}
+
+func TestRecordErrors(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewRegistry()
+	rec := NewRecorder(&buf)
+	r.SetRecorder(rec)
+	e := r.CreateEntity()
+	AddComponent(r, e, TestComponent1{X: 1})
+	assert.ErrorContains(t, rec.Err(), "not registered")
+
+	_, err := NewReplayer(NewRegistry(), strings.NewReader(`{"version":100}`))
+	assert.Error(t, err)
+
+	// recorded entity id is different
+	data := "{\"version\":1}\n{\"op\":\"createEntity\",\"entity\":\"5:1\"}\n"
+	rp, err := NewReplayer(NewRegistry(), strings.NewReader(data))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, rp.Run(context.Background()), ErrDesync)
+}
+
+func newRecordObserverRegistry() *Registry {
+	r := newRecordTestRegistry()
+	o := r.AddObserver("spawnVel", func(ctx *ObserverContext) error {
+		// observer mutation is not recorded but reproduced
+		e := ctx.registry.CreateEntity()
+		AddComponent(ctx.registry, e, RollbackVel{V: 1})
+		return nil
+	})
+	AddComponentToObserver[RollbackPos](o)
+	return r
+}
+
+func TestRecordObserverMutation(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+
+	r := newRecordObserverRegistry()
+	rec := NewRecorder(&buf)
+	r.SetRecorder(rec)
+	e, err := r.CreateEntityImmediate()
+	assert.NoError(t, err)
+	assert.NoError(t, AddComponentImmediate(r, e, RollbackPos{X: 1}))
+	assert.NoError(t, r.Tick(time.Millisecond, ctx))
+	assert.NoError(t, rec.Err())
+
+	replayed := newRecordObserverRegistry()
+	rp, err := NewReplayer(replayed, bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	assert.NoError(t, rp.Run(ctx))
+	assert.Equal(t, r.Hash(), replayed.Hash())
+}
+
+func TestRecordCommandBuffer(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+
+	r := newRecordTestRegistry()
+	rec := NewRecorder(&buf)
+	r.SetRecorder(rec)
+
+	// id of buffer is issued before entity that is created directly, but buffer is submitted later
+	cb := r.NewCommandBuffer()
+	e1 := cb.CreateEntity()
+	AddComponentCommand(cb, e1, RollbackPos{X: 1})
+	AddComponentCommand(cb, e1, RollbackVel{V: 1})
+	e2 := r.CreateEntity()
+	AddComponent(r, e2, RollbackPos{X: 2})
+	AddComponent(r, e2, RollbackVel{V: 2})
+	r.SubmitCommandBuffer(cb)
+	// discarded id is reused by next entity
+	discarded := cb.CreateEntity()
+	cb.Discard()
+	e3 := r.CreateEntity()
+	assert.Equal(t, discarded.Index(), e3.Index())
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, r.Tick(time.Millisecond, ctx))
+	}
+	assert.NoError(t, rec.Err())
+
+	replayed := newRecordTestRegistry()
+	rp, err := NewReplayer(replayed, bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	assert.NoError(t, rp.Run(ctx))
+	assert.Equal(t, r.Hash(), replayed.Hash())
+	assert.Equal(t, int64(4), GetEntityComponent[RollbackPos](replayed, e1).X)
+	assert.Equal(t, int64(8), GetEntityComponent[RollbackPos](replayed, e2).X)
+	assert.True(t, replayed.IsActiveEntity(e3))
+	assert.False(t, replayed.IsActiveEntity(discarded))
+}
+
+func TestRecordAsyncSystem(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+
+	r := NewRegistry()
+	rec := NewRecorder(&buf)
+	r.SetRecorder(rec)
+	proceed := make(chan struct{})
+	release := make(chan struct{})
+	as := r.AddAsyncSystem("spawner", 0, func(ctx *ExecutionContext) (AsyncWorkFn, error) {
+		return func(ctx context.Context, commands *CommandBuffer) error {
+			<-proceed
+			// id is issued on background goroutine between ticks
+			commands.CreateEntity()
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			return nil
+		}, nil
+	})
+
+	assert.NoError(t, r.Tick(time.Millisecond, ctx))
+	proceed <- struct{}{}
+	release <- struct{}{}
+	waitAsyncDone(as)
+	assert.NoError(t, r.Tick(time.Millisecond, ctx))
+	assert.True(t, r.IsActiveEntity(EntityId{id: 1, version: 1}))
+
+	// work is cancelled by Close and its id is released
+	assert.NoError(t, r.Tick(time.Millisecond, ctx))
+	proceed <- struct{}{}
+	r.Close()
+	assert.NoError(t, rec.Err())
+
+	// work runs again on replay, so its ids are not recorded
+	assert.NotContains(t, buf.String(), recordOpReserveEntity)
+	assert.NotContains(t, buf.String(), recordOpReleaseEntity)
+}
@@ -3,6 +3,8 @@ package ecsgo
 import (
 	"context"
 	"reflect"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -14,29 +16,52 @@ type Registry struct {
 	eg              *executionGroup
 	deferredActions *deferredActions
 
-	archeTypeList      []*ArcheType
-	systems            []*System
-	observers          []*Observer
-	entityArcheTypeMap map[EntityId]*ArcheType
+	archeTypeList []*ArcheType
+	systems       []*System
+	observers     []*Observer
+	entities      entityTable
+
+	errHandler            errorHandler
+	deferredObserverCalls []*observerCall
 
 	duringTick int32
+	debug      bool
+	recorder   *Recorder
+	jobs       jobScheduler
+
+	asyncMx      sync.Mutex
+	asyncSystems []*AsyncSystem
+
+	viewMx sync.Mutex
+	views  []*WorldView
 
 	// for issue new id
 	mx         sync.Mutex
 	lastId     uint32
 	tombstones []EntityId
+	// reserved has ids that are issued but not written to entities yet,
+	// entities is only written at flush so systems can read it without lock
+	reserved      map[EntityId]struct{}
+	reservedCount atomic.Int32
 }
 
 func NewRegistry() *Registry {
 	r := &Registry{
-		eg:                 newExecutionGroup(),
-		entityArcheTypeMap: make(map[EntityId]*ArcheType),
+		eg: newExecutionGroup(),
 	}
 	r.deferredActions = newDeferredActions(r)
 	return r
 }
 
 func (r *Registry) CreateEntity() EntityId {
+	entityId := r.issueEntityId()
+	r.deferredActions.createEntity(entityId)
+	return entityId
+}
+
+// issueEntityId reserves new entity id, it is safe to call from concurrently running systems.
+// Reserved id is written to entity table at next flush and entity is created by createEntityAction
+func (r *Registry) issueEntityId() EntityId {
 	r.mx.Lock()
 
 	var entityId EntityId
@@ -54,14 +79,63 @@ func (r *Registry) CreateEntity() EntityId {
 			version: 1,
 		}
 	}
-	// setting archetype, currently it is just for checking issued or not
-	r.entityArcheTypeMap[entityId] = nil
+	if r.reserved == nil {
+		r.reserved = make(map[EntityId]struct{})
+	}
+	r.reserved[entityId] = struct{}{}
+	r.reservedCount.Add(1)
 	r.mx.Unlock()
-
-	r.deferredActions.createEntity(entityId)
 	return entityId
 }
 
+// isReserved returns true if id is issued but not written to entity table yet
+func (r *Registry) isReserved(entityId EntityId) bool {
+	if r.reservedCount.Load() == 0 {
+		return false
+	}
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	_, found := r.reserved[entityId]
+	return found
+}
+
+// issueReservedIds writes reserved ids to entity table, it should be called when systems are not running
+func (r *Registry) issueReservedIds() {
+	if r.reservedCount.Load() == 0 {
+		return
+	}
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	for entityId := range r.reserved {
+		r.entities.issue(entityId)
+	}
+	clear(r.reserved)
+	r.reservedCount.Store(0)
+}
+
+// releaseEntityIds returns ids that are issued but never created to tombstones and returns them.
+// Id that is already written to entity table is removed by next flush
+func (r *Registry) releaseEntityIds(entityIds []EntityId) []EntityId {
+	var released, issued []EntityId
+	r.mx.Lock()
+	for _, entityId := range entityIds {
+		if _, found := r.reserved[entityId]; !found {
+			issued = append(issued, entityId)
+			continue
+		}
+		delete(r.reserved, entityId)
+		r.reservedCount.Add(-1)
+		r.tombstones = append(r.tombstones, entityId)
+		released = append(released, entityId)
+	}
+	r.mx.Unlock()
+
+	for _, entityId := range issued {
+		r.deferredActions.removeEntity(entityId)
+	}
+	return released
+}
+
 func (r *Registry) RemoveEntity(entityId EntityId) {
 	r.deferredActions.removeEntity(entityId)
 }
@@ -74,6 +148,102 @@ func RemoveComponent[T any](r *Registry, entityId EntityId) {
 	removeComponentDeferredAction[T](r.deferredActions, entityId)
 }
 
+// NewCommandBuffer makes command buffer that can be recorded on any goroutine and submitted later by SubmitCommandBuffer
+func (r *Registry) NewCommandBuffer() *CommandBuffer {
+	return newCommandBuffer(r)
+}
+
+// SubmitCommandBuffer moves recorded commands to deferred actions, they are applied on next flush
+func (r *Registry) SubmitCommandBuffer(cb *CommandBuffer) {
+	r.deferredActions.playback(cb)
+}
+
+// Flush applies deferred actions immediately, it can't be called during Tick
+func (r *Registry) Flush() error {
+	if r.isTicking() {
+		return ErrTickInProgress
+	}
+	if r.recorder != nil {
+		r.recorder.recordFlush()
+	}
+	return r.processDeferredActions()
+}
+
+// CreateEntityImmediate creates entity and flushes, it can't be called during Tick
+func (r *Registry) CreateEntityImmediate() (EntityId, error) {
+	if r.isTicking() {
+		return EntityId{}, ErrTickInProgress
+	}
+	entityId := r.CreateEntity()
+	return entityId, r.Flush()
+}
+
+// RemoveEntityImmediate removes entity and flushes, it can't be called during Tick
+func (r *Registry) RemoveEntityImmediate(entityId EntityId) error {
+	err := r.checkImmediate("RemoveEntity", entityId, nil)
+	if err != nil {
+		return err
+	}
+	r.RemoveEntity(entityId)
+	return r.Flush()
+}
+
+// AddComponentImmediate adds component and flushes, it can't be called during Tick
+func AddComponentImmediate[T any](r *Registry, entityId EntityId, val T) error {
+	err := r.checkImmediate("AddComponent", entityId, reflect.TypeOf(val))
+	if err != nil {
+		return err
+	}
+	AddComponent(r, entityId, val)
+	return r.Flush()
+}
+
+// RemoveComponentImmediate removes component and flushes, it can't be called during Tick
+func RemoveComponentImmediate[T any](r *Registry, entityId EntityId) error {
+	var t T
+	err := r.checkImmediate("RemoveComponent", entityId, reflect.TypeOf(t))
+	if err != nil {
+		return err
+	}
+	RemoveComponent[T](r, entityId)
+	return r.Flush()
+}
+
+// GetEntityComponent returns component of entity outside systems, pending deferred actions are not applied
+func GetEntityComponent[T any](r *Registry, entityId EntityId) *T {
+	a := r.entities.getArcheType(entityId)
+	if a == nil {
+		return nil
+	}
+	return getArcheTypeComponent[T](a, entityId)
+}
+
+func (r *Registry) isTicking() bool {
+	return atomic.LoadInt32(&r.duringTick) != 0
+}
+
+func (r *Registry) checkImmediate(op string, entityId EntityId, ty reflect.Type) error {
+	if r.isTicking() {
+		return ErrTickInProgress
+	}
+	err := r.checkEntity(entityId)
+	if err != nil {
+		return &EntityError{Op: op, EntityId: entityId, ComponentType: ty, Err: err}
+	}
+	return nil
+}
+
+// checkEntity returns ErrStaleEntity if entity was issued but removed or reused, ErrEntityNotFound if it is never issued
+func (r *Registry) checkEntity(entityId EntityId) error {
+	if r.IsActiveEntity(entityId) {
+		return nil
+	}
+	if r.IsStale(entityId) {
+		return ErrStaleEntity
+	}
+	return ErrEntityNotFound
+}
+
 func (r *Registry) AddSystem(name string, priority int, fn SystemFn) *System {
 	s := newSystem(r, name, priority, fn)
 	r.deferredActions.addSystem(s)
@@ -86,35 +256,175 @@ func (r *Registry) AddObserver(name string, fn ObserverFunc) *Observer {
 	return o
 }
 
+// SetErrorPolicy sets how errors during deferred actions flush are handled, default is ErrorPolicyAbort
+func (r *Registry) SetErrorPolicy(policy ErrorPolicy) {
+	r.errHandler.policy = policy
+}
+
+// AddBatchObserver adds observer that is called once at the end of flush with all interested entities
+func (r *Registry) AddBatchObserver(name string, fn BatchObserverFunc) *Observer {
+	o := newBatchObserver(r, name, fn)
+	r.deferredActions.addObserver(o)
+	return o
+}
+
+// IsActiveEntity returns true if entity is issued and not removed, it is safe to call from systems
 func (r *Registry) IsActiveEntity(entityId EntityId) bool {
-	_, found := r.entityArcheTypeMap[entityId]
-	return found
+	return r.entities.get(entityId) != nil || r.isReserved(entityId)
+}
+
+// IsStale returns true if entity was issued but it is removed or its id is reused by newer entity
+func (r *Registry) IsStale(entityId EntityId) bool {
+	return r.entities.isStale(entityId)
+}
+
+// Validate returns nil if entity is alive, ErrStaleEntity if it is removed or reused, otherwise ErrEntityNotFound
+func (r *Registry) Validate(entityId EntityId) error {
+	return r.checkEntity(entityId)
+}
+
+// SetDebug enables debug checks, for example systems that touch stale entities are reported as ErrStaleEntity on flush
+func (r *Registry) SetDebug(debug bool) {
+	r.debug = debug
+}
+
+// SetSequential runs systems one by one on goroutine that calls Tick instead of running independent systems in parallel
+func (r *Registry) SetSequential(sequential bool) {
+	r.eg.sequential = sequential
+}
+
+// SetWorkerCount sets number of workers that run systems, GOMAXPROCS if n is not positive.
+// Workers are reused across ticks and stopped when they are idle, it can't be changed during Tick
+func (r *Registry) SetWorkerCount(n int) {
+	r.eg.setWorkerCount(n)
+}
+
+// Close cancels works of async systems and stops workers of registry, workers are started again if registry is ticked after.
+// It should be called when registry is not used anymore and can't be called from systems of registry
+func (r *Registry) Close() {
+	r.cancelAsyncSystems()
+	r.eg.close()
+}
+
+// reportStaleAccess reports when system accesses stale entity in debug mode
+func (r *Registry) reportStaleAccess(op string, systemName string, entityId EntityId, ty reflect.Type) {
+	if !r.debug || !r.IsStale(entityId) {
+		return
+	}
+	err := &EntityError{Op: op, EntityId: entityId, ComponentType: ty, Err: ErrStaleEntity}
+	r.deferredActions.reportError(errors.Wrapf(err, "system %s", systemName))
 }
 
 func (r *Registry) Tick(deltaTime time.Duration, ctx context.Context) error {
+	if r.recorder != nil && !r.isTicking() {
+		r.recorder.recordTick(deltaTime)
+	}
 	atomic.StoreInt32(&r.duringTick, 1)
 	defer func() {
 		atomic.StoreInt32(&r.duringTick, 0)
 	}()
 
-	err := r.processDeferredActions()
+	// jobs scheduled outside Tick are completed before systems run, their error is returned with jobs of this tick
+	outsideJobErr := r.completeJobs()
+	// errors collected by ErrorPolicyCollect are returned at the end of tick, so systems still run
+	err := r.deferredActions.process()
 	if err != nil {
 		return err
 	}
 	err = r.eg.execute(deltaTime, ctx)
+	// sync point of system command buffers, jobs and async systems
+	r.playbackSystemCommands()
+	jobErr := r.completeJobs()
+	if jobErr == nil {
+		jobErr = outsideJobErr
+	}
+	asyncErr := r.syncAsyncSystems()
 	if err != nil {
 		return err
 	}
+	if jobErr != nil {
+		return jobErr
+	}
+	if asyncErr != nil {
+		return asyncErr
+	}
 	// processDeferred again that process deferred actions while processing Systems
-	return r.processDeferredActions()
+	err = r.deferredActions.process()
+	if err != nil {
+		return err
+	}
+	r.publishViews()
+	return r.errHandler.takeCollected()
 }
 
 func (r *Registry) processDeferredActions() error {
-	return r.deferredActions.process()
+	err := r.deferredActions.process()
+	if err != nil {
+		return err
+	}
+	return r.errHandler.takeCollected()
+}
+
+// playbackSystemCommands plays back command buffers of systems in system registration order
+func (r *Registry) playbackSystemCommands() {
+	for _, s := range r.systems {
+		if s.commands != nil {
+			r.deferredActions.playback(s.commands)
+		}
+	}
 }
 
 func (r *Registry) addObserverSync(o *Observer) {
 	r.observers = append(r.observers, o)
+	slices.SortStableFunc(r.observers, func(a, b *Observer) int {
+		return b.priority - a.priority
+	})
+}
+
+func (r *Registry) notifyObservers(entityId EntityId, archeType *ArcheType, added, removed []reflect.Type) error {
+	for _, o := range r.observers {
+		call := o.makeCallIfInterest(entityId, archeType, added, removed)
+		if call == nil {
+			continue
+		}
+		if o.IsBatch() {
+			o.batch = append(o.batch, call)
+			continue
+		}
+		if o.mode == ObserverDeferred {
+			r.deferredObserverCalls = append(r.deferredObserverCalls, call)
+			continue
+		}
+		err := r.errHandler.handle(call.execute())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushDeferredObservers calls observers with ObserverDeferred mode in order and then batch observers
+func (r *Registry) flushDeferredObservers() error {
+	for len(r.deferredObserverCalls) > 0 {
+		call := r.deferredObserverCalls[0]
+		r.deferredObserverCalls[0] = nil
+		r.deferredObserverCalls = r.deferredObserverCalls[1:]
+
+		err := r.errHandler.handle(call.execute())
+		if err != nil {
+			return err
+		}
+	}
+	for _, o := range r.observers {
+		if !o.IsBatch() {
+			continue
+		}
+		err := r.errHandler.handle(o.executeBatch())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (r *Registry) addSystemSync(s *System) {
@@ -126,76 +436,98 @@ func (r *Registry) addSystemSync(s *System) {
 }
 
 func (r *Registry) removeEntitySync(entityId EntityId) error {
-	a := r.entityArcheTypeMap[entityId]
+	err := r.checkEntity(entityId)
+	if err != nil {
+		return r.errHandler.handle(&EntityError{Op: "RemoveEntity", EntityId: entityId, Err: err})
+	}
+	a := r.entities.getArcheType(entityId)
 	if a != nil {
 		a.removeEntity(entityId)
 	}
-	delete(r.entityArcheTypeMap, entityId)
-	// it is threadsafe so don't need to lock because it is only called on deferredActions
+	r.entities.remove(entityId)
+	// systems or async works can issue id while flushing
+	r.mx.Lock()
 	r.tombstones = append(r.tombstones, entityId)
+	r.mx.Unlock()
 
-	// call observers
-	removed := a.getComponentTypeList()
-	for _, o := range r.observers {
-		err := o.executeIfInterest(entityId, a, nil, removed)
-		if err != nil {
-			return nil
-		}
+	if a == nil {
+		// no component, nothing to notify
+		return nil
 	}
-	return nil
+	// components of archetype are in map order, so sort them to notify observers in same order every run
+	removed := a.getComponentTypeList()
+	slices.SortFunc(removed, func(x, y reflect.Type) int {
+		return strings.Compare(x.String(), y.String())
+	})
+	return r.notifyObservers(entityId, a, nil, removed)
 }
 
 func (r *Registry) processEntityActionSync(entityId EntityId, actions []entityAction) error {
 	if len(actions) == 0 {
 		return nil
 	}
+	err := r.checkEntity(entityId)
+	if err != nil {
+		return r.errHandler.handle(newEntityError(actions[0], entityId, err))
+	}
 
 	var types []reflect.Type
 	var added []reflect.Type
 	var removed []reflect.Type
-	archeType := r.entityArcheTypeMap[entityId]
+	rec := r.entities.get(entityId)
+	archeType, oldIdx := rec.archeType, rec.row
 	if archeType != nil {
 		types = archeType.getComponentTypeList()
 	}
 
+	// invalid actions are skipped and reported after valid actions are applied
+	var actionErrs []error
 	for _, action := range actions {
-		action.modifyTypes(&types, &added, &removed)
+		err = action.modifyTypes(&types, &added, &removed)
+		if err != nil {
+			actionErrs = append(actionErrs, newEntityError(action, entityId, err))
+		}
 	}
 
 	targetArcheType := r.getOrMakeArcheTypeSync(types)
-	if targetArcheType != nil {
-		targetArcheType.addEntity(entityId)
+	if targetArcheType != nil && archeType != targetArcheType {
+		newIdx := targetArcheType.addEntity(entityId)
 
-		if archeType != nil && archeType != targetArcheType {
+		if archeType != nil {
 			// move component data from old to new archeType
 			origtypes := archeType.getComponentTypeList()
 			for _, t := range origtypes {
 				if targetArcheType.hasComponent(t) {
-					err := archeType.copyDataToOtherArcheType(entityId, t, targetArcheType)
+					err := archeType.copyDataToOtherArcheType(oldIdx, t, targetArcheType, newIdx)
 					if err != nil {
 						return errors.Errorf("failed to move data from old to new archetype %v", err)
 					}
 				}
 			}
 		}
-
+	}
+	if archeType != nil && archeType != targetArcheType {
+		// location is already changed to target, so remove by old index
+		archeType.removeEntityByIdx(oldIdx)
+	}
+	if targetArcheType == nil {
+		r.entities.setLocation(entityId, nil, 0)
+	} else {
 		for _, action := range actions {
 			action.apply(entityId, targetArcheType)
 		}
 	}
-	if archeType != nil {
-		archeType.removeEntity(entityId)
-	}
-	r.entityArcheTypeMap[entityId] = targetArcheType
 
-	// call observers
-	for _, o := range r.observers {
-		err := o.executeIfInterest(entityId, targetArcheType, added, removed)
+	err = r.notifyObservers(entityId, targetArcheType, added, removed)
+	if err != nil {
+		return err
+	}
+	for _, err := range actionErrs {
+		err = r.errHandler.handle(err)
 		if err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
 
@@ -208,7 +540,7 @@ func (r *Registry) getOrMakeArcheTypeSync(types []reflect.Type) *ArcheType {
 			return a
 		}
 	}
-	newArcheType := newArcheType(types...)
+	newArcheType := newArcheTypeWithTable(&r.entities, types...)
 	r.archeTypeList = append(r.archeTypeList, newArcheType)
 	r.onAddArcheType(newArcheType)
 	return newArcheType
@@ -0,0 +1,378 @@
+package ecsgo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeferredActionsOrder(t *testing.T) {
+	for round := 0; round < 10; round++ {
+		r := NewRegistry()
+
+		var observed []EntityId
+		o := r.AddObserver("order", func(ctx *ObserverContext) error {
+			observed = append(observed, ctx.GetEntityId())
+			return nil
+		})
+		AddComponentToObserver[TestComponent1](o)
+
+		var created []EntityId
+		for i := 0; i < 50; i++ {
+			created = append(created, r.CreateEntity())
+		}
+		for i := len(created) - 1; i >= 0; i-- {
+			AddComponent(r, created[i], TestComponent1{X: i})
+		}
+
+		err := r.Tick(time.Second, context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, created, observed)
+
+		a := r.entities.getArcheType(created[0])
+		assert.NotNil(t, a)
+		assert.Equal(t, created, a.enitityIds)
+	}
+}
+
+func TestCommandBuffer(t *testing.T) {
+	r := NewRegistry()
+
+	spawn := func(ctx *ExecutionContext) error {
+		cmd := ctx.Commands()
+		for i := 0; i < 100; i++ {
+			e := cmd.CreateEntity()
+			AddComponentCommand(cmd, e, TestComponent1{X: i})
+		}
+		return nil
+	}
+	// independent systems run in parallel
+	sys1 := r.AddSystem("spawn1", 0, spawn)
+	AddReadWriteComponent[TestComponent2](sys1.NewQuery())
+	sys2 := r.AddSystem("spawn2", 0, spawn)
+	AddReadWriteComponent[TestComponent3](sys2.NewQuery())
+
+	err := r.Tick(time.Second, context.Background())
+	assert.NoError(t, err)
+
+	var a *ArcheType
+	for _, at := range r.archeTypeList {
+		if HasArcheTypeComponent[TestComponent1](at) {
+			a = at
+		}
+	}
+	assert.NotNil(t, a)
+	assert.Equal(t, 200, a.getEntityCount())
+
+	// sys1 is registered first, so its commands are played back first
+	for i := 0; i < 200; i++ {
+		acc := a.getAceessorByIdx(i)
+		assert.Equal(t, i%100, GetComponentByAccessor[TestComponent1](acc).X)
+	}
+
+	// user command buffer recorded on other goroutine
+	cb := r.NewCommandBuffer()
+	done := make(chan EntityId)
+	go func() {
+		e := cb.CreateEntity()
+		AddComponentCommand(cb, e, TestComponent1{X: 1000})
+		RemoveComponentCommand[TestComponent1](cb, e)
+		AddComponentCommand(cb, e, TestComponent2{V: 1})
+		done <- e
+	}()
+	e := <-done
+	r.SubmitCommandBuffer(cb)
+	assert.Equal(t, 0, cb.Len())
+
+	err = r.Tick(time.Second, context.Background())
+	assert.NoError(t, err)
+	// systems spawned again
+	assert.Equal(t, 400, a.getEntityCount())
+	a2 := r.entities.getArcheType(e)
+	assert.NotNil(t, a2)
+	assert.False(t, HasArcheTypeComponent[TestComponent1](a2))
+	assert.Equal(t, 1.0, getArcheTypeComponent[TestComponent2](a2, e).V)
+}
+
+func TestDiscardCommandBuffer(t *testing.T) {
+	r := NewRegistry()
+
+	cb := r.NewCommandBuffer()
+	e := cb.CreateEntity()
+	AddComponentCommand(cb, e, TestComponent1{X: 1})
+	assert.True(t, r.IsActiveEntity(e))
+	cb.Discard()
+	assert.Equal(t, 0, cb.Len())
+	assert.False(t, r.IsActiveEntity(e))
+	assert.NoError(t, r.Flush())
+	assert.False(t, r.IsActiveEntity(e))
+
+	// released id is reused
+	reused := r.CreateEntity()
+	assert.Equal(t, e.Index(), reused.Index())
+	assert.Equal(t, e.Version()+1, reused.Version())
+
+	// id is written to entity table by flush before buffer is discarded
+	e = cb.CreateEntity()
+	assert.NoError(t, r.Flush())
+	assert.True(t, r.IsActiveEntity(e))
+	cb.Discard()
+	assert.NoError(t, r.Flush())
+	assert.False(t, r.IsActiveEntity(e))
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.Save(&buf))
+	assert.NotContains(t, buf.String(), fmt.Sprintf(`"id":"%v"`, e))
+}
+
+func TestImmediate(t *testing.T) {
+	r := NewRegistry()
+
+	e, err := r.CreateEntityImmediate()
+	assert.NoError(t, err)
+	err = AddComponentImmediate(r, e, TestComponent1{X: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 10, GetEntityComponent[TestComponent1](r, e).X)
+
+	// deferred one is visible after Flush
+	AddComponent(r, e, TestComponent2{V: 1})
+	assert.Nil(t, GetEntityComponent[TestComponent2](r, e))
+	assert.NoError(t, r.Flush())
+	assert.Equal(t, 1.0, GetEntityComponent[TestComponent2](r, e).V)
+	assert.Equal(t, 10, GetEntityComponent[TestComponent1](r, e).X)
+
+	err = RemoveComponentImmediate[TestComponent1](r, e)
+	assert.NoError(t, err)
+	assert.Nil(t, GetEntityComponent[TestComponent1](r, e))
+
+	err = r.RemoveEntityImmediate(e)
+	assert.NoError(t, err)
+	assert.False(t, r.IsActiveEntity(e))
+
+	err = AddComponentImmediate(r, e, TestComponent1{X: 10})
+	assert.ErrorIs(t, err, ErrEntityNotFound)
+	err = r.RemoveEntityImmediate(e)
+	assert.ErrorIs(t, err, ErrEntityNotFound)
+
+	// not allowed during tick
+	r.AddSystem("flush", 0, func(ctx *ExecutionContext) error {
+		assert.ErrorIs(t, ctx.GetResgiry().Flush(), ErrTickInProgress)
+		_, err := ctx.GetResgiry().CreateEntityImmediate()
+		assert.ErrorIs(t, err, ErrTickInProgress)
+		return nil
+	})
+	assert.NoError(t, r.Tick(time.Second, context.Background()))
+}
+
+func TestEntityErrors(t *testing.T) {
+	r := NewRegistry()
+
+	e, err := r.CreateEntityImmediate()
+	assert.NoError(t, err)
+	assert.NoError(t, AddComponentImmediate(r, e, TestComponent1{X: 1}))
+
+	// component value is changed without moving archetype
+	AddComponent(r, e, TestComponent1{X: 2})
+	assert.NoError(t, r.Flush())
+	assert.Equal(t, 2, GetEntityComponent[TestComponent1](r, e).X)
+
+	RemoveComponent[TestComponent2](r, e)
+	err = r.Flush()
+	var entityErr *EntityError
+	assert.ErrorAs(t, err, &entityErr)
+	assert.ErrorIs(t, err, ErrComponentMissing)
+	assert.Equal(t, "RemoveComponent", entityErr.Op)
+	assert.Equal(t, e, entityErr.EntityId)
+	assert.Equal(t, reflect.TypeOf(TestComponent2{}), entityErr.ComponentType)
+	assert.Equal(t, 2, GetEntityComponent[TestComponent1](r, e).X)
+
+	r.RemoveEntity(e)
+	AddComponent(r, e, TestComponent2{V: 1})
+	err = r.Flush()
+	assert.ErrorIs(t, err, ErrStaleEntity)
+	assert.ErrorIs(t, err, ErrEntityNotFound)
+
+	AddComponent(r, e, TestComponent2{V: 1})
+	err = r.Flush()
+	assert.ErrorIs(t, err, ErrStaleEntity)
+	assert.ErrorAs(t, err, &entityErr)
+	assert.Equal(t, "AddComponent", entityErr.Op)
+
+	notIssued := EntityId{id: 100, version: 1}
+	r.RemoveEntity(notIssued)
+	err = r.Flush()
+	assert.ErrorIs(t, err, ErrEntityNotFound)
+	assert.NotErrorIs(t, err, ErrStaleEntity)
+
+	// collect instead of abort
+	r.SetErrorPolicy(ErrorPolicyCollect)
+	valid, err := r.CreateEntityImmediate()
+	assert.NoError(t, err)
+	RemoveComponent[TestComponent1](r, e)
+	AddComponent(r, valid, TestComponent1{X: 3})
+	RemoveComponent[TestComponent2](r, valid)
+	err = r.Tick(time.Second, context.Background())
+	var flushErrs FlushErrors
+	assert.ErrorAs(t, err, &flushErrs)
+	assert.Len(t, flushErrs, 2)
+	assert.Equal(t, 3, GetEntityComponent[TestComponent1](r, valid).X)
+}
+
+func TestStaleEntity(t *testing.T) {
+	r := NewRegistry()
+
+	e, err := r.CreateEntityImmediate()
+	assert.NoError(t, err)
+	assert.NoError(t, r.Validate(e))
+	assert.False(t, r.IsStale(e))
+
+	assert.NoError(t, r.RemoveEntityImmediate(e))
+	assert.True(t, r.IsStale(e))
+	assert.ErrorIs(t, r.Validate(e), ErrStaleEntity)
+	// sentinel itself is also not found entity
+	assert.ErrorIs(t, r.Validate(e), ErrEntityNotFound)
+	assert.ErrorIs(t, ErrStaleEntity, ErrEntityNotFound)
+
+	// id is reused with new version
+	reused, err := r.CreateEntityImmediate()
+	assert.NoError(t, err)
+	assert.Equal(t, e.id, reused.id)
+	assert.Equal(t, e.version+1, reused.version)
+	assert.True(t, r.IsStale(e))
+	assert.False(t, r.IsActiveEntity(e))
+	assert.True(t, r.IsActiveEntity(reused))
+
+	notIssued := EntityId{id: reused.id, version: reused.version + 1}
+	assert.False(t, r.IsStale(notIssued))
+	assert.ErrorIs(t, r.Validate(notIssued), ErrEntityNotFound)
+	assert.NotErrorIs(t, r.Validate(notIssued), ErrStaleEntity)
+
+	// debug mode reports system touching recycled entity
+	assert.NoError(t, AddComponentImmediate(r, reused, TestComponent1{}))
+	r.SetDebug(true)
+	sys := r.AddSystem("touchStale", 0, func(ctx *ExecutionContext) error {
+		assert.Nil(t, GetComponent[TestComponent1](ctx, e))
+		return nil
+	})
+	AddReadonlyComponent[TestComponent1](sys.NewQuery())
+
+	// stale access is reported on the flush at the end of tick
+	err = r.Tick(time.Second, context.Background())
+	assert.ErrorIs(t, err, ErrStaleEntity)
+	var entityErr *EntityError
+	assert.ErrorAs(t, err, &entityErr)
+	assert.Equal(t, e, entityErr.EntityId)
+	assert.Equal(t, "GetComponent", entityErr.Op)
+}
+
+func TestConcurrentEntityAccess(t *testing.T) {
+	r := NewRegistry()
+	defer r.Close()
+	r.SetWorkerCount(8)
+
+	var seeds []EntityId
+	for i := 0; i < 100; i++ {
+		e := r.CreateEntity()
+		AddComponent(r, e, TestComponent1{X: i})
+		seeds = append(seeds, e)
+	}
+	assert.NoError(t, r.Flush())
+
+	var mx sync.Mutex
+	created := make(map[EntityId]bool)
+	for i := 0; i < 8; i++ {
+		sys := r.AddSystem(fmt.Sprintf("spawner%d", i), 0, func(ctx *ExecutionContext) error {
+			var ids []EntityId
+			for j := 0; j < 50; j++ {
+				var e EntityId
+				if j%2 == 0 {
+					e = ctx.CreateEntity()
+				} else {
+					e = ctx.Commands().CreateEntity()
+				}
+				AddComponentCommand(ctx.Commands(), e, TestComponent2{V: float64(j)})
+				if !ctx.GetResgiry().IsActiveEntity(e) {
+					return fmt.Errorf("created entity %v is not active", e)
+				}
+				for _, seed := range seeds[:10] {
+					if GetComponent[TestComponent1](ctx, seed) == nil {
+						return fmt.Errorf("component of %v is not found", seed)
+					}
+				}
+				ids = append(ids, e)
+			}
+			// half of entities are removed so their ids are recycled on next ticks
+			for _, e := range ids[:25] {
+				ctx.Commands().RemoveEntity(e)
+			}
+
+			mx.Lock()
+			defer mx.Unlock()
+			for _, e := range ids {
+				if created[e] {
+					return fmt.Errorf("entity %v is issued twice", e)
+				}
+				created[e] = true
+			}
+			return nil
+		})
+		AddReadonlyComponent[TestComponent1](sys.NewQuery())
+	}
+
+	for i := 0; i < 50; i++ {
+		assert.NoError(t, r.Tick(time.Millisecond, context.Background()))
+	}
+	assert.Len(t, created, 8*50*50)
+
+	active := 0
+	for e := range created {
+		if r.IsActiveEntity(e) {
+			active++
+			assert.NotNil(t, GetEntityComponent[TestComponent2](r, e))
+		}
+	}
+	assert.Equal(t, 8*50*25, active)
+}
+
+func TestCollectErrorsAcrossTick(t *testing.T) {
+	r := NewRegistry()
+	defer r.Close()
+	r.SetErrorPolicy(ErrorPolicyCollect)
+	e, err := r.CreateEntityImmediate()
+	assert.NoError(t, err)
+	assert.NoError(t, AddComponentImmediate(r, e, TestComponent1{X: 1}))
+
+	var missing EntityId
+	sys := r.AddSystem("move", 0, func(ctx *ExecutionContext) error {
+		if !missing.NotNil() {
+			missing = ctx.CreateEntity()
+			// error of post-system flush is collected together
+			RemoveComponent[TestComponent2](ctx.GetResgiry(), missing)
+		}
+		return ctx.GetQueryResult(0).ForeachEntities(func(accessor *ArcheTypeAccessor) error {
+			GetComponentByAccessor[TestComponent1](accessor).X++
+			return nil
+		})
+	})
+	AddReadWriteComponent[TestComponent1](sys.NewQuery())
+
+	// error of pre-system flush doesn't stop systems
+	RemoveComponent[TestComponent2](r, e)
+	err = r.Tick(time.Second, context.Background())
+	var flushErrs FlushErrors
+	assert.ErrorAs(t, err, &flushErrs)
+	assert.Len(t, flushErrs, 2)
+	assert.ErrorIs(t, flushErrs[0], ErrComponentMissing)
+	assert.ErrorIs(t, flushErrs[1], ErrComponentMissing)
+	assert.Equal(t, 2, GetEntityComponent[TestComponent1](r, e).X)
+	assert.True(t, r.IsActiveEntity(missing))
+
+	// collected errors are not returned again
+	assert.NoError(t, r.Tick(time.Second, context.Background()))
+}
@@ -0,0 +1,448 @@
+package ecsgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"slices"
+
+	"github.com/pkg/errors"
+)
+
+// DeltaPacket has changes of replicated entities from BaseTick to Tick, it is full state if BaseTick is 0
+type DeltaPacket struct {
+	Tick      uint64             `json:"tick"`
+	BaseTick  uint64             `json:"baseTick,omitempty"`
+	Spawned   []ReplicatedEntity `json:"spawned,omitempty"`
+	Despawned []EntityId         `json:"despawned,omitempty"`
+	Changed   []ReplicatedEntity `json:"changed,omitempty"`
+}
+
+// ReplicatedEntity is replicated components of server entity
+type ReplicatedEntity struct {
+	Id EntityId `json:"id"`
+	// Components are whole values of added or changed components
+	Components map[string]json.RawMessage `json:"components,omitempty"`
+	// Fields are changed fields of components, only for components that are JSON object
+	Fields map[string]json.RawMessage `json:"fields,omitempty"`
+	// Removed are names of removed components
+	Removed []string `json:"removed,omitempty"`
+}
+
+// IsFull returns true if packet has full state instead of delta
+func (p *DeltaPacket) IsFull() bool {
+	return p.BaseTick == 0
+}
+
+// replicatedState is JSON value of replicated components by entity
+type replicatedState map[EntityId]map[string]json.RawMessage
+
+type replicatedFrame struct {
+	tick  uint64
+	state replicatedState
+}
+
+// Replicator captures replicated components of registry on server and makes delta packets for clients.
+// Only components that are set by ComponentType.SetReplicated are sent,
+// entities that don't have any replicated component are not sent.
+type Replicator struct {
+	registry *Registry
+	// history of captured states, last one is latest
+	frames  []replicatedFrame
+	maxSize int
+	tick    uint64
+}
+
+// ReplicationClient keeps acknowledged tick of client
+type ReplicationClient struct {
+	ackTick uint64
+	// components are names of components that client receives, all replicated components if nil
+	components map[string]bool
+}
+
+func NewReplicator(r *Registry, historySize int) *Replicator {
+	if historySize < 1 {
+		historySize = 1
+	}
+	return &Replicator{
+		registry: r,
+		maxSize:  historySize,
+	}
+}
+
+// NewClient makes client that receives full state at first
+func (rep *Replicator) NewClient() *ReplicationClient {
+	return &ReplicationClient{}
+}
+
+// Ack sets tick that client received, next delta is based on it
+func (c *ReplicationClient) Ack(tick uint64) {
+	if tick > c.ackTick {
+		c.ackTick = tick
+	}
+}
+
+func (c *ReplicationClient) GetAckTick() uint64 {
+	return c.ackTick
+}
+
+// GetTick returns last captured tick, it starts from 1
+func (rep *Replicator) GetTick() uint64 {
+	return rep.tick
+}
+
+// Capture captures current state of replicated components as new tick, it should be called after Tick
+func (rep *Replicator) Capture() (uint64, error) {
+	if rep.registry.isTicking() {
+		return 0, ErrTickInProgress
+	}
+	state, err := captureReplicatedState(rep.registry)
+	if err != nil {
+		return 0, err
+	}
+	rep.tick++
+	if len(rep.frames) == rep.maxSize {
+		rep.frames = slices.Delete(rep.frames, 0, 1)
+	}
+	rep.frames = append(rep.frames, replicatedFrame{tick: rep.tick, state: state})
+	return rep.tick, nil
+}
+
+// MakeDelta makes packet of latest captured tick based on acknowledged tick of client,
+// it is full state if acknowledged tick is not kept anymore
+func (rep *Replicator) MakeDelta(client *ReplicationClient) (*DeltaPacket, error) {
+	if len(rep.frames) == 0 {
+		return nil, errors.New("nothing is captured")
+	}
+	latest := rep.frames[len(rep.frames)-1]
+	var base replicatedFrame
+	for _, frame := range rep.frames {
+		if frame.tick == client.ackTick {
+			base = frame
+			break
+		}
+	}
+	baseState, state := base.state, latest.state
+	if client.components != nil {
+		baseState = filterReplicatedState(baseState, client.components)
+		state = filterReplicatedState(state, client.components)
+	}
+	return makeDeltaPacket(base.tick, baseState, latest.tick, state), nil
+}
+
+// filterReplicatedState returns state that has only given components
+func filterReplicatedState(state replicatedState, components map[string]bool) replicatedState {
+	filtered := make(replicatedState, len(state))
+	for entityId, values := range state {
+		var kept map[string]json.RawMessage
+		for name, data := range values {
+			if !components[name] {
+				continue
+			}
+			if kept == nil {
+				kept = make(map[string]json.RawMessage)
+			}
+			kept[name] = data
+		}
+		if kept != nil {
+			filtered[entityId] = kept
+		}
+	}
+	return filtered
+}
+
+func captureReplicatedState(r *Registry) (replicatedState, error) {
+	state := make(replicatedState)
+	for _, a := range r.archeTypeList {
+		if a.getEntityCount() == 0 {
+			continue
+		}
+		var cts []*ComponentType
+		for _, ty := range a.getComponentTypeList() {
+			if ct := getComponentType(ty); ct != nil && ct.replicated {
+				cts = append(cts, ct)
+			}
+		}
+		if len(cts) == 0 {
+			continue
+		}
+		for row, entityId := range a.enitityIds {
+			components := make(map[string]json.RawMessage, len(cts))
+			for _, ct := range cts {
+				data, err := json.Marshal(ct.getValue(a, row))
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to marshal component %s of entity %v", ct.name, entityId)
+				}
+				components[ct.name] = data
+			}
+			state[entityId] = components
+		}
+	}
+	return state, nil
+}
+
+func makeDeltaPacket(baseTick uint64, base replicatedState, tick uint64, cur replicatedState) *DeltaPacket {
+	p := &DeltaPacket{
+		Tick:     tick,
+		BaseTick: baseTick,
+	}
+	for _, entityId := range sortedStateEntityIds(cur) {
+		components := cur[entityId]
+		baseComponents, found := base[entityId]
+		if !found {
+			p.Spawned = append(p.Spawned, ReplicatedEntity{Id: entityId, Components: components})
+			continue
+		}
+		changed := ReplicatedEntity{Id: entityId}
+		for name, data := range components {
+			baseData, found := baseComponents[name]
+			if found && bytes.Equal(baseData, data) {
+				continue
+			}
+			if found {
+				if fields, ok := diffFields(baseData, data); ok {
+					if changed.Fields == nil {
+						changed.Fields = make(map[string]json.RawMessage)
+					}
+					changed.Fields[name] = fields
+					continue
+				}
+			}
+			if changed.Components == nil {
+				changed.Components = make(map[string]json.RawMessage)
+			}
+			changed.Components[name] = data
+		}
+		for name := range baseComponents {
+			if _, found := components[name]; !found {
+				changed.Removed = append(changed.Removed, name)
+			}
+		}
+		slices.Sort(changed.Removed)
+		if changed.Components != nil || changed.Fields != nil || changed.Removed != nil {
+			p.Changed = append(p.Changed, changed)
+		}
+	}
+	for _, entityId := range sortedStateEntityIds(base) {
+		if _, found := cur[entityId]; !found {
+			p.Despawned = append(p.Despawned, entityId)
+		}
+	}
+	return p
+}
+
+// diffFields returns object of changed fields, false if values are not objects or field is omitted
+func diffFields(base, cur json.RawMessage) (json.RawMessage, bool) {
+	var baseFields, curFields map[string]json.RawMessage
+	if json.Unmarshal(base, &baseFields) != nil || json.Unmarshal(cur, &curFields) != nil {
+		return nil, false
+	}
+	if baseFields == nil || curFields == nil {
+		return nil, false
+	}
+	changed := make(map[string]json.RawMessage)
+	for name, data := range curFields {
+		if !bytes.Equal(baseFields[name], data) {
+			changed[name] = data
+		}
+	}
+	for name := range baseFields {
+		if _, found := curFields[name]; !found {
+			// omitted field can't be patched
+			return nil, false
+		}
+	}
+	data, err := json.Marshal(changed)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// mergeFields returns base object with fields overwritten
+func mergeFields(base, fields json.RawMessage) (json.RawMessage, error) {
+	var baseFields, changed map[string]json.RawMessage
+	err := json.Unmarshal(base, &baseFields)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(fields, &changed)
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(baseFields, changed)
+	return json.Marshal(baseFields)
+}
+
+func sortedStateEntityIds(state replicatedState) []EntityId {
+	entityIds := make([]EntityId, 0, len(state))
+	for entityId := range state {
+		entityIds = append(entityIds, entityId)
+	}
+	slices.SortFunc(entityIds, func(a, b EntityId) int {
+		return int(a.id) - int(b.id)
+	})
+	return entityIds
+}
+
+func sortedComponentNames(components map[string]json.RawMessage) []string {
+	names := make([]string, 0, len(components))
+	for name := range components {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// DeltaApplier applies delta packets to client registry, server entities are mapped to local entities.
+// Changes are recorded as deferred actions, so they are applied on next Flush or Tick.
+type DeltaApplier struct {
+	registry *Registry
+	// history of received states to apply delta based on old tick, last one is applied state
+	frames  []replicatedFrame
+	maxSize int
+
+	localIds  map[EntityId]EntityId
+	serverIds map[EntityId]EntityId
+}
+
+func NewDeltaApplier(r *Registry, historySize int) *DeltaApplier {
+	if historySize < 1 {
+		historySize = 1
+	}
+	return &DeltaApplier{
+		registry:  r,
+		maxSize:   historySize,
+		localIds:  make(map[EntityId]EntityId),
+		serverIds: make(map[EntityId]EntityId),
+	}
+}
+
+// GetTick returns tick of last applied packet, it should be acknowledged to server
+func (d *DeltaApplier) GetTick() uint64 {
+	if len(d.frames) == 0 {
+		return 0
+	}
+	return d.frames[len(d.frames)-1].tick
+}
+
+// GetLocalEntity returns local entity of server entity
+func (d *DeltaApplier) GetLocalEntity(serverId EntityId) (EntityId, bool) {
+	localId, found := d.localIds[serverId]
+	return localId, found
+}
+
+// GetServerEntity returns server entity of local entity
+func (d *DeltaApplier) GetServerEntity(localId EntityId) (EntityId, bool) {
+	serverId, found := d.serverIds[localId]
+	return serverId, found
+}
+
+// Apply applies packet, old packet than applied one is ignored.
+// It returns ErrUnknownBaseline if base tick of packet is not kept.
+func (d *DeltaApplier) Apply(p *DeltaPacket) error {
+	if p.Tick <= d.GetTick() {
+		return nil
+	}
+	base := replicatedState{}
+	if !p.IsFull() {
+		idx := slices.IndexFunc(d.frames, func(frame replicatedFrame) bool {
+			return frame.tick == p.BaseTick
+		})
+		if idx < 0 {
+			return errors.Wrapf(ErrUnknownBaseline, "tick %d", p.BaseTick)
+		}
+		base = d.frames[idx].state
+	}
+
+	state := maps.Clone(base)
+	for _, entity := range p.Spawned {
+		state[entity.Id] = maps.Clone(entity.Components)
+	}
+	for _, entityId := range p.Despawned {
+		delete(state, entityId)
+	}
+	for _, entity := range p.Changed {
+		components := maps.Clone(state[entity.Id])
+		if components == nil {
+			return errors.Errorf("changed entity %v is not in base tick %d", entity.Id, p.BaseTick)
+		}
+		maps.Copy(components, entity.Components)
+		for name, fields := range entity.Fields {
+			merged, err := mergeFields(components[name], fields)
+			if err != nil {
+				return errors.Wrapf(err, "failed to merge component %s of entity %v", name, entity.Id)
+			}
+			components[name] = merged
+		}
+		for _, name := range entity.Removed {
+			delete(components, name)
+		}
+		state[entity.Id] = components
+	}
+
+	var applied replicatedState
+	if len(d.frames) > 0 {
+		applied = d.frames[len(d.frames)-1].state
+	}
+	err := d.applyState(applied, state)
+	if err != nil {
+		return err
+	}
+	if len(d.frames) == d.maxSize {
+		d.frames = slices.Delete(d.frames, 0, 1)
+	}
+	d.frames = append(d.frames, replicatedFrame{tick: p.Tick, state: state})
+	return nil
+}
+
+// applyState records actions to change registry from applied state to new state
+func (d *DeltaApplier) applyState(applied, state replicatedState) error {
+	r := d.registry
+	for _, serverId := range sortedStateEntityIds(applied) {
+		if _, found := state[serverId]; found {
+			continue
+		}
+		localId := d.localIds[serverId]
+		r.RemoveEntity(localId)
+		delete(d.localIds, serverId)
+		delete(d.serverIds, localId)
+	}
+	for _, serverId := range sortedStateEntityIds(state) {
+		components := state[serverId]
+		appliedComponents := applied[serverId]
+		localId, found := d.localIds[serverId]
+		if !found {
+			localId = r.CreateEntity()
+			d.localIds[serverId] = localId
+			d.serverIds[localId] = serverId
+		}
+		for _, name := range sortedComponentNames(components) {
+			data := components[name]
+			if bytes.Equal(appliedComponents[name], data) {
+				continue
+			}
+			ct := getComponentTypeByName(name)
+			if ct == nil {
+				return errors.Errorf("component %s is not registered", name)
+			}
+			ptr := ct.newValue()
+			err := json.Unmarshal(data, ptr)
+			if err != nil {
+				return errors.Wrapf(err, "failed to unmarshal component %s of entity %v", name, serverId)
+			}
+			ct.addComponent(r, localId, ptr)
+		}
+		for _, name := range sortedComponentNames(appliedComponents) {
+			if _, found := components[name]; found {
+				continue
+			}
+			ct := getComponentTypeByName(name)
+			if ct == nil {
+				return errors.Errorf("component %s is not registered", name)
+			}
+			ct.removeComponent(r, localId)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package ecsgo
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type RepPos struct {
+	X, Y int
+}
+
+type RepHealth struct {
+	HP int
+}
+
+type RepServerOnly struct {
+	Secret int
+}
+
+func init() {
+	RegisterComponent[RepPos]("RepPos").SetReplicated(true)
+	RegisterComponent[RepHealth]("RepHealth").SetReplicated(true)
+	RegisterComponent[RepServerOnly]("RepServerOnly")
+}
+
+// sendPacket sends packet through JSON like network
+func sendPacket(t *testing.T, p *DeltaPacket) *DeltaPacket {
+	data, err := json.Marshal(p)
+	assert.NoError(t, err)
+	var received DeltaPacket
+	assert.NoError(t, json.Unmarshal(data, &received))
+	return &received
+}
+
+func TestReplication(t *testing.T) {
+	server := NewRegistry()
+	rep := NewReplicator(server, 8)
+	clientState := rep.NewClient()
+
+	e1 := server.CreateEntity()
+	AddComponent(server, e1, RepPos{X: 1, Y: 1})
+	AddComponent(server, e1, RepHealth{HP: 100})
+	AddComponent(server, e1, RepServerOnly{Secret: 7})
+	e2 := server.CreateEntity()
+	AddComponent(server, e2, RepPos{X: 2, Y: 2})
+	// not replicated
+	e3 := server.CreateEntity()
+	AddComponent(server, e3, RepServerOnly{})
+	assert.NoError(t, server.Flush())
+
+	tick, err := rep.Capture()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), tick)
+
+	client := NewRegistry()
+	applier := NewDeltaApplier(client, 8)
+	p, err := rep.MakeDelta(clientState)
+	assert.NoError(t, err)
+	assert.True(t, p.IsFull())
+	assert.Len(t, p.Spawned, 2)
+	assert.NoError(t, applier.Apply(sendPacket(t, p)))
+	assert.NoError(t, client.Flush())
+	clientState.Ack(applier.GetTick())
+
+	local1, found := applier.GetLocalEntity(e1)
+	assert.True(t, found)
+	serverId, _ := applier.GetServerEntity(local1)
+	assert.Equal(t, e1, serverId)
+	assert.Equal(t, RepPos{X: 1, Y: 1}, *GetEntityComponent[RepPos](client, local1))
+	assert.Equal(t, RepHealth{HP: 100}, *GetEntityComponent[RepHealth](client, local1))
+	assert.Nil(t, GetEntityComponent[RepServerOnly](client, local1))
+	_, found = applier.GetLocalEntity(e3)
+	assert.False(t, found)
+
+	// delta
+	AddComponent(server, e1, RepPos{X: 10, Y: 1})
+	RemoveComponent[RepHealth](server, e1)
+	server.RemoveEntity(e2)
+	e4 := server.CreateEntity()
+	AddComponent(server, e4, RepHealth{HP: 50})
+	assert.NoError(t, server.Flush())
+	_, err = rep.Capture()
+	assert.NoError(t, err)
+
+	p, err = rep.MakeDelta(clientState)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), p.BaseTick)
+	assert.Equal(t, []EntityId{e2}, p.Despawned)
+	assert.Len(t, p.Spawned, 1)
+	assert.Equal(t, e4, p.Spawned[0].Id)
+	assert.Len(t, p.Changed, 1)
+	assert.Equal(t, e1, p.Changed[0].Id)
+	assert.JSONEq(t, `{"X":10}`, string(p.Changed[0].Fields["RepPos"]))
+	assert.Equal(t, []string{"RepHealth"}, p.Changed[0].Removed)
+
+	local2, _ := applier.GetLocalEntity(e2)
+	assert.NoError(t, applier.Apply(sendPacket(t, p)))
+	assert.NoError(t, client.Flush())
+	assert.Equal(t, RepPos{X: 10, Y: 1}, *GetEntityComponent[RepPos](client, local1))
+	assert.Nil(t, GetEntityComponent[RepHealth](client, local1))
+	assert.False(t, client.IsActiveEntity(local2))
+	local4, _ := applier.GetLocalEntity(e4)
+	assert.Equal(t, RepHealth{HP: 50}, *GetEntityComponent[RepHealth](client, local4))
+
+	// ack of tick 2 is lost, so next delta is based on tick 1 again
+	AddComponent(server, e1, RepPos{X: 1, Y: 1})
+	assert.NoError(t, server.Flush())
+	_, err = rep.Capture()
+	assert.NoError(t, err)
+	p, err = rep.MakeDelta(clientState)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), p.BaseTick)
+	assert.NoError(t, applier.Apply(sendPacket(t, p)))
+	assert.NoError(t, client.Flush())
+	assert.Equal(t, RepPos{X: 1, Y: 1}, *GetEntityComponent[RepPos](client, local1))
+	assert.Equal(t, uint64(3), applier.GetTick())
+
+	// old packet is ignored
+	assert.NoError(t, applier.Apply(&DeltaPacket{Tick: 2, BaseTick: 1}))
+
+	// new client doesn't have base tick
+	other := NewDeltaApplier(NewRegistry(), 8)
+	assert.ErrorIs(t, other.Apply(p), ErrUnknownBaseline)
+}
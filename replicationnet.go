@@ -0,0 +1,511 @@
+package ecsgo
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const replicationProtocolVersion = 1
+
+// replicationWriteTimeout disconnects client that doesn't receive
+const replicationWriteTimeout = 5 * time.Second
+
+// replicationHandshakeTimeout disconnects client that doesn't send hello
+const replicationHandshakeTimeout = 5 * time.Second
+
+// defaultReplicationMaxMessageSize disconnects client that sends larger message, client only sends hello and acks
+const defaultReplicationMaxMessageSize = 1 << 20
+
+// replicationSendQueueSize is number of messages that are queued for client,
+// client is disconnected if queue is full because it doesn't receive fast enough
+const replicationSendQueueSize = 16
+
+const (
+	replicationMsgHello   = "hello"
+	replicationMsgWelcome = "welcome"
+	replicationMsgReject  = "reject"
+	replicationMsgDelta   = "delta"
+	replicationMsgAck     = "ack"
+)
+
+// replicationMessage is message of replication protocol, messages are sent as JSON lines
+type replicationMessage struct {
+	Type       string            `json:"type"`
+	Version    int               `json:"version,omitempty"`
+	Components []componentSchema `json:"components,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Tick       uint64            `json:"tick,omitempty"`
+	Packet     *DeltaPacket      `json:"packet,omitempty"`
+}
+
+// componentSchema is registered name and Go type of component
+type componentSchema struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func registeredComponentSchemas(replicatedOnly bool) []componentSchema {
+	componentTypes.mx.RLock()
+	defer componentTypes.mx.RUnlock()
+
+	var schemas []componentSchema
+	for _, ct := range componentTypes.byName {
+		if replicatedOnly && !ct.replicated {
+			continue
+		}
+		schemas = append(schemas, componentSchema{Name: ct.name, Type: ct.ty.String()})
+	}
+	slices.SortFunc(schemas, func(a, b componentSchema) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return schemas
+}
+
+// ReplicationServer streams replicated components of registry to clients that are connected by TCP
+type ReplicationServer struct {
+	replicator *Replicator
+
+	mx       sync.Mutex
+	listener net.Listener
+	// conns has all accepted connections including handshaking ones, they are closed by Close
+	conns   map[net.Conn]struct{}
+	clients map[*replicationConn]struct{}
+	closed  bool
+	wg      sync.WaitGroup
+
+	maxMessageSize int
+}
+
+type replicationConn struct {
+	conn net.Conn
+	enc  *json.Encoder
+	// send has messages that are written by writing goroutine, so slow client doesn't block Broadcast
+	send chan *replicationMessage
+	done chan struct{}
+	// mx guards client that is acknowledged by reading goroutine
+	mx     sync.Mutex
+	client *ReplicationClient
+}
+
+func NewReplicationServer(r *Registry, historySize int) *ReplicationServer {
+	return &ReplicationServer{
+		replicator:     NewReplicator(r, historySize),
+		conns:          make(map[net.Conn]struct{}),
+		clients:        make(map[*replicationConn]struct{}),
+		maxMessageSize: defaultReplicationMaxMessageSize,
+	}
+}
+
+// Listen starts accepting clients on addr like "127.0.0.1:0"
+func (s *ReplicationServer) Listen(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.mx.Lock()
+	s.listener = l
+	s.mx.Unlock()
+
+	s.wg.Add(1)
+	go s.accept(l)
+	return nil
+}
+
+// Addr returns listening address, nil if it is not listening
+func (s *ReplicationServer) Addr() net.Addr {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// GetClientCount returns number of clients that finished handshake
+func (s *ReplicationServer) GetClientCount() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return len(s.clients)
+}
+
+func (s *ReplicationServer) accept(l net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		s.mx.Lock()
+		if s.closed {
+			s.mx.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mx.Unlock()
+		go s.serve(conn)
+	}
+}
+
+func (s *ReplicationServer) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer s.removeConn(conn)
+
+	mr := &messageReader{
+		rd:      bufio.NewReader(conn),
+		maxSize: s.maxMessageSize,
+	}
+	rc := &replicationConn{
+		conn: conn,
+		enc:  json.NewEncoder(conn),
+		send: make(chan *replicationMessage, replicationSendQueueSize),
+		done: make(chan struct{}),
+	}
+	conn.SetReadDeadline(time.Now().Add(replicationHandshakeTimeout))
+	client, welcome, err := s.handshake(mr, rc.enc)
+	if err != nil {
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+	rc.client = client
+
+	// welcome is queued before client is registered, so it is sent before deltas of all ticks after handshake
+	rc.send <- welcome
+	s.mx.Lock()
+	if s.closed {
+		s.mx.Unlock()
+		return
+	}
+	s.clients[rc] = struct{}{}
+	s.wg.Add(1)
+	s.mx.Unlock()
+	defer s.removeClient(rc)
+	go s.write(rc)
+	defer close(rc.done)
+
+	for {
+		var msg replicationMessage
+		err := mr.read(&msg)
+		if err != nil {
+			return
+		}
+		if msg.Type == replicationMsgAck {
+			rc.mx.Lock()
+			rc.client.Ack(msg.Tick)
+			rc.mx.Unlock()
+		}
+	}
+}
+
+// handshake checks protocol version and negotiates components,
+// only replicated components that client registered with same type are sent
+func (s *ReplicationServer) handshake(mr *messageReader, enc *json.Encoder) (*ReplicationClient, *replicationMessage, error) {
+	var hello replicationMessage
+	err := mr.read(&hello)
+	if err != nil {
+		return nil, nil, err
+	}
+	if hello.Type != replicationMsgHello || hello.Version != replicationProtocolVersion {
+		err = errors.Errorf("unsupported hello %s version %d", hello.Type, hello.Version)
+		enc.Encode(&replicationMessage{Type: replicationMsgReject, Error: err.Error()})
+		return nil, nil, err
+	}
+
+	clientTypes := make(map[string]string, len(hello.Components))
+	for _, schema := range hello.Components {
+		clientTypes[schema.Name] = schema.Type
+	}
+	client := s.replicator.NewClient()
+	client.components = make(map[string]bool)
+	var accepted []componentSchema
+	for _, schema := range registeredComponentSchemas(true) {
+		if clientTypes[schema.Name] == schema.Type {
+			client.components[schema.Name] = true
+			accepted = append(accepted, schema)
+		}
+	}
+	welcome := &replicationMessage{
+		Type:       replicationMsgWelcome,
+		Version:    replicationProtocolVersion,
+		Components: accepted,
+	}
+	return client, welcome, nil
+}
+
+// write sends queued messages to client, client is disconnected if it fails
+func (s *ReplicationServer) write(rc *replicationConn) {
+	defer s.wg.Done()
+	for {
+		select {
+		case msg := <-rc.send:
+			rc.conn.SetWriteDeadline(time.Now().Add(replicationWriteTimeout))
+			err := rc.enc.Encode(msg)
+			if err != nil {
+				// reading goroutine removes client
+				rc.conn.Close()
+				return
+			}
+		case <-rc.done:
+			return
+		}
+	}
+}
+
+func (s *ReplicationServer) removeClient(rc *replicationConn) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	delete(s.clients, rc)
+}
+
+func (s *ReplicationServer) removeConn(conn net.Conn) {
+	conn.Close()
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	delete(s.conns, conn)
+}
+
+// Broadcast captures current state as new tick and queues delta to all clients, it should be called after Tick.
+// Deltas are written by goroutine of each client, client that failed to send or has full queue is disconnected.
+func (s *ReplicationServer) Broadcast() (uint64, error) {
+	tick, err := s.replicator.Capture()
+	if err != nil {
+		return 0, err
+	}
+
+	s.mx.Lock()
+	conns := make([]*replicationConn, 0, len(s.clients))
+	for rc := range s.clients {
+		conns = append(conns, rc)
+	}
+	s.mx.Unlock()
+
+	for _, rc := range conns {
+		rc.mx.Lock()
+		p, err := s.replicator.MakeDelta(rc.client)
+		rc.mx.Unlock()
+		if err != nil {
+			return tick, err
+		}
+		select {
+		case rc.send <- &replicationMessage{Type: replicationMsgDelta, Packet: p}:
+		default:
+			// client doesn't receive fast enough, reading goroutine removes client
+			rc.conn.Close()
+		}
+	}
+	return tick, nil
+}
+
+// Close stops listening and disconnects all clients including clients in handshake
+func (s *ReplicationServer) Close() error {
+	s.mx.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mx.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// messageReader reads JSON line messages, line that is longer than maxSize is rejected without buffering it
+type messageReader struct {
+	rd      *bufio.Reader
+	maxSize int
+}
+
+func (mr *messageReader) read(msg *replicationMessage) error {
+	var line []byte
+	for {
+		chunk, err := mr.rd.ReadSlice('\n')
+		if len(line)+len(chunk) > mr.maxSize {
+			return errors.Errorf("message is larger than %d bytes", mr.maxSize)
+		}
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(line, msg)
+	}
+}
+
+// MirrorClient keeps mirror registry in sync with ReplicationServer.
+// Packets are received in background and applied by Sync or WaitTick on goroutine that owns registry.
+type MirrorClient struct {
+	conn    net.Conn
+	enc     *json.Encoder
+	applier *DeltaApplier
+	// components that server accepted to send
+	components []string
+
+	packets chan *DeltaPacket
+	mx      sync.Mutex
+	err     error
+	wg      sync.WaitGroup
+}
+
+// DialMirror connects to server and finishes handshake, received entities are created in r
+func DialMirror(addr string, r *Registry, historySize int) (*MirrorClient, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &MirrorClient{
+		conn:    conn,
+		enc:     json.NewEncoder(conn),
+		applier: NewDeltaApplier(r, historySize),
+		packets: make(chan *DeltaPacket, 64),
+	}
+	dec := json.NewDecoder(conn)
+	err = c.handshake(dec)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c.wg.Add(1)
+	go c.receive(dec)
+	return c, nil
+}
+
+func (c *MirrorClient) handshake(dec *json.Decoder) error {
+	err := c.enc.Encode(&replicationMessage{
+		Type:       replicationMsgHello,
+		Version:    replicationProtocolVersion,
+		Components: registeredComponentSchemas(false),
+	})
+	if err != nil {
+		return err
+	}
+	var welcome replicationMessage
+	err = dec.Decode(&welcome)
+	if err != nil {
+		return errors.Wrap(err, "failed to receive welcome")
+	}
+	if welcome.Type == replicationMsgReject {
+		return errors.Errorf("rejected by server: %s", welcome.Error)
+	}
+	if welcome.Type != replicationMsgWelcome {
+		return errors.Errorf("unexpected message %s", welcome.Type)
+	}
+	for _, schema := range welcome.Components {
+		c.components = append(c.components, schema.Name)
+	}
+	return nil
+}
+
+func (c *MirrorClient) receive(dec *json.Decoder) {
+	defer c.wg.Done()
+	defer close(c.packets)
+	for {
+		var msg replicationMessage
+		err := dec.Decode(&msg)
+		if err != nil {
+			c.setErr(err)
+			return
+		}
+		if msg.Type == replicationMsgDelta && msg.Packet != nil {
+			c.packets <- msg.Packet
+		}
+	}
+}
+
+func (c *MirrorClient) setErr(err error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// Err returns error that stopped receiving
+func (c *MirrorClient) Err() error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.err
+}
+
+// GetComponents returns names of components that server sends
+func (c *MirrorClient) GetComponents() []string {
+	return c.components
+}
+
+// GetApplier returns applier that has mapping of server entities
+func (c *MirrorClient) GetApplier() *DeltaApplier {
+	return c.applier
+}
+
+// Sync applies all received packets without blocking, changes are applied on next Flush or Tick of registry
+func (c *MirrorClient) Sync() (int, error) {
+	var n int
+	for {
+		select {
+		case p, ok := <-c.packets:
+			if !ok {
+				return n, c.Err()
+			}
+			err := c.apply(p)
+			if err != nil {
+				return n, err
+			}
+			n++
+		default:
+			return n, nil
+		}
+	}
+}
+
+// WaitTick applies received packets until tick is applied
+func (c *MirrorClient) WaitTick(ctx context.Context, tick uint64) error {
+	for c.applier.GetTick() < tick {
+		select {
+		case p, ok := <-c.packets:
+			if !ok {
+				return c.Err()
+			}
+			err := c.apply(p)
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (c *MirrorClient) apply(p *DeltaPacket) error {
+	err := c.applier.Apply(p)
+	if err != nil {
+		return err
+	}
+	return c.enc.Encode(&replicationMessage{Type: replicationMsgAck, Tick: c.applier.GetTick()})
+}
+
+// Close disconnects from server
+func (c *MirrorClient) Close() error {
+	err := c.conn.Close()
+	// drain packets so receiving goroutine is not blocked
+	go func() {
+		for range c.packets {
+		}
+	}()
+	c.wg.Wait()
+	return err
+}
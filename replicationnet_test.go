@@ -0,0 +1,204 @@
+package ecsgo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicationServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	server := NewRegistry()
+	rs := NewReplicationServer(server, 16)
+	assert.NoError(t, rs.Listen("127.0.0.1:0"))
+	defer rs.Close()
+
+	e := server.CreateEntity()
+	AddComponent(server, e, RepPos{X: 1})
+	AddComponent(server, e, RepServerOnly{Secret: 1})
+	assert.NoError(t, server.Flush())
+
+	client := NewRegistry()
+	mc, err := DialMirror(rs.Addr().String(), client, 16)
+	assert.NoError(t, err)
+	defer mc.Close()
+	assert.Equal(t, []string{"RepHealth", "RepPos"}, mc.GetComponents())
+	assert.Equal(t, 1, rs.GetClientCount())
+
+	for i := 0; i < 10; i++ {
+		AddComponent(server, e, RepPos{X: i, Y: i * 2})
+		if i == 5 {
+			AddComponent(server, e, RepHealth{HP: 10})
+		}
+		assert.NoError(t, server.Tick(time.Millisecond, ctx))
+		tick, err := rs.Broadcast()
+		assert.NoError(t, err)
+
+		assert.NoError(t, mc.WaitTick(ctx, tick))
+		assert.NoError(t, client.Tick(time.Millisecond, ctx))
+		local, found := mc.GetApplier().GetLocalEntity(e)
+		assert.True(t, found)
+		assert.Equal(t, RepPos{X: i, Y: i * 2}, *GetEntityComponent[RepPos](client, local))
+		assert.Nil(t, GetEntityComponent[RepServerOnly](client, local))
+		if i >= 5 {
+			assert.Equal(t, RepHealth{HP: 10}, *GetEntityComponent[RepHealth](client, local))
+		}
+	}
+
+	n, err := mc.Sync()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestReplicationHandshake(t *testing.T) {
+	rs := NewReplicationServer(NewRegistry(), 4)
+	assert.NoError(t, rs.Listen("127.0.0.1:0"))
+	defer rs.Close()
+
+	handshake := func(hello replicationMessage) replicationMessage {
+		conn, err := net.Dial("tcp", rs.Addr().String())
+		assert.NoError(t, err)
+		defer conn.Close()
+		assert.NoError(t, json.NewEncoder(conn).Encode(&hello))
+		var reply replicationMessage
+		assert.NoError(t, json.NewDecoder(conn).Decode(&reply))
+		return reply
+	}
+
+	// client that has only RepPos with same type
+	reply := handshake(replicationMessage{
+		Type:    replicationMsgHello,
+		Version: replicationProtocolVersion,
+		Components: []componentSchema{
+			{Name: "RepPos", Type: "ecsgo.RepPos"},
+			{Name: "RepHealth", Type: "other.RepHealth"},
+		},
+	})
+	assert.Equal(t, replicationMsgWelcome, reply.Type)
+	assert.Equal(t, []componentSchema{{Name: "RepPos", Type: "ecsgo.RepPos"}}, reply.Components)
+
+	reply = handshake(replicationMessage{Type: replicationMsgHello, Version: 100})
+	assert.Equal(t, replicationMsgReject, reply.Type)
+	assert.NotEmpty(t, reply.Error)
+}
+
+func TestReplicationServerCloseDuringHandshake(t *testing.T) {
+	rs := NewReplicationServer(NewRegistry(), 4)
+	assert.NoError(t, rs.Listen("127.0.0.1:0"))
+
+	// client connects but doesn't send hello
+	conn, err := net.Dial("tcp", rs.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	// wait until server accepts connection
+	for {
+		rs.mx.Lock()
+		accepted := len(rs.conns)
+		rs.mx.Unlock()
+		if accepted > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		rs.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Close is blocked by client in handshake")
+	}
+
+	// connection is closed by server
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, os.ErrDeadlineExceeded))
+}
+
+// dialRaw connects to server and finishes handshake without MirrorClient
+func dialRaw(t *testing.T, rs *ReplicationServer) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", rs.Addr().String())
+	assert.NoError(t, err)
+	assert.NoError(t, json.NewEncoder(conn).Encode(&replicationMessage{
+		Type:       replicationMsgHello,
+		Version:    replicationProtocolVersion,
+		Components: registeredComponentSchemas(false),
+	}))
+	rd := bufio.NewReader(conn)
+	line, err := rd.ReadBytes('\n')
+	assert.NoError(t, err)
+	var welcome replicationMessage
+	assert.NoError(t, json.Unmarshal(line, &welcome))
+	assert.Equal(t, replicationMsgWelcome, welcome.Type)
+	return conn, rd
+}
+
+func waitClientCount(t *testing.T, rs *ReplicationServer, n int) {
+	deadline := time.Now().Add(3 * time.Second)
+	for rs.GetClientCount() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("client count %d is not %d", rs.GetClientCount(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReplicationServerLargeMessage(t *testing.T) {
+	rs := NewReplicationServer(NewRegistry(), 4)
+	rs.maxMessageSize = 1024
+	assert.NoError(t, rs.Listen("127.0.0.1:0"))
+	defer rs.Close()
+
+	conn, rd := dialRaw(t, rs)
+	defer conn.Close()
+	waitClientCount(t, rs, 1)
+
+	// message without end of line is not buffered without limit
+	_, err := conn.Write(bytes.Repeat([]byte("a"), 4096))
+	assert.NoError(t, err)
+	waitClientCount(t, rs, 0)
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, err = rd.ReadByte()
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, os.ErrDeadlineExceeded))
+}
+
+func TestReplicationServerSlowClient(t *testing.T) {
+	server := NewRegistry()
+	for i := 0; i < 1000; i++ {
+		e := server.CreateEntity()
+		AddComponent(server, e, RepPos{X: i})
+	}
+	assert.NoError(t, server.Flush())
+	rs := NewReplicationServer(server, 4)
+	assert.NoError(t, rs.Listen("127.0.0.1:0"))
+	defer rs.Close()
+
+	// client doesn't read nor ack, so every delta has all entities
+	slow, _ := dialRaw(t, rs)
+	defer slow.Close()
+	waitClientCount(t, rs, 1)
+
+	start := time.Now()
+	for i := 0; i < 200 && rs.GetClientCount() > 0; i++ {
+		_, err := rs.Broadcast()
+		assert.NoError(t, err)
+	}
+	// Broadcast doesn't wait for slow client, which is disconnected when its queue is full
+	assert.Less(t, time.Since(start), replicationWriteTimeout)
+	waitClientCount(t, rs, 0)
+}
@@ -0,0 +1,213 @@
+package ecsgo
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RollbackInputFunc applies inputs of tick to registry before tick is executed, inputs are in added order
+type RollbackInputFunc func(r *Registry, tick uint64, inputs []any) error
+
+type rollbackFrame struct {
+	tick  uint64
+	valid bool
+	// snapshot of registry before tick inputs are applied
+	snapshot []byte
+	// hash of world state after tick is executed
+	hash uint64
+}
+
+// Rollback runs registry with fixed delta time and keeps snapshots of last ticks,
+// so it can restore to past tick when late inputs arrive and re-simulate up to present.
+// State of systems themselves is not restored, systems should keep their state in components.
+type Rollback struct {
+	registry  *Registry
+	deltaTime time.Duration
+	inputFn   RollbackInputFunc
+
+	frames []rollbackFrame
+	inputs map[uint64][]any
+	// next tick to be executed
+	tick uint64
+	// oldest tick that received late input, re-simulated on next Tick
+	dirtyTick uint64
+	dirty     bool
+}
+
+func NewRollback(r *Registry, capacity int, deltaTime time.Duration, inputFn RollbackInputFunc) *Rollback {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Rollback{
+		registry:  r,
+		deltaTime: deltaTime,
+		inputFn:   inputFn,
+		frames:    make([]rollbackFrame, capacity),
+		inputs:    make(map[uint64][]any),
+	}
+}
+
+// GetTick returns next tick to be executed
+func (rb *Rollback) GetTick() uint64 {
+	return rb.tick
+}
+
+// GetHash returns world state hash after tick is executed, false if tick is not kept
+func (rb *Rollback) GetHash(tick uint64) (uint64, bool) {
+	frame := rb.getFrame(tick)
+	if frame == nil {
+		return 0, false
+	}
+	return frame.hash, true
+}
+
+// AddInput adds input of tick, if tick is already executed it is re-simulated on next Tick
+func (rb *Rollback) AddInput(tick uint64, input any) error {
+	if tick < rb.tick {
+		if rb.getFrame(tick) == nil {
+			return errors.Wrapf(ErrRollbackTooOld, "tick %d", tick)
+		}
+		if !rb.dirty || tick < rb.dirtyTick {
+			rb.dirtyTick = tick
+			rb.dirty = true
+		}
+	}
+	rb.inputs[tick] = append(rb.inputs[tick], input)
+	return nil
+}
+
+// Tick re-simulates ticks that received late inputs and executes next tick.
+// If a tick fails, registry is restored to state before that tick and it is executed again on next Tick
+func (rb *Rollback) Tick(ctx context.Context) error {
+	if rb.dirty {
+		err := rb.RollbackTo(rb.dirtyTick, ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return rb.simulate(ctx)
+}
+
+// RollbackTo restores registry to state before tick is executed and re-simulates up to present
+func (rb *Rollback) RollbackTo(tick uint64, ctx context.Context) error {
+	frame := rb.getFrame(tick)
+	if frame == nil {
+		return errors.Wrapf(ErrRollbackTooOld, "tick %d", tick)
+	}
+	err := rb.registry.Restore(frame.snapshot)
+	if err != nil {
+		return errors.Wrapf(err, "failed to restore tick %d", tick)
+	}
+	rb.dirty = false
+
+	present := rb.tick
+	rb.tick = tick
+	for rb.tick < present {
+		err = rb.simulate(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verify re-simulates from oldest kept tick and returns ErrDesync if world state hash is different
+func (rb *Rollback) Verify(ctx context.Context) error {
+	oldest, found := rb.oldestTick()
+	if !found {
+		return nil
+	}
+	expected := make(map[uint64]uint64)
+	for t := oldest; t < rb.tick; t++ {
+		expected[t], _ = rb.GetHash(t)
+	}
+	err := rb.RollbackTo(oldest, ctx)
+	if err != nil {
+		return err
+	}
+	for t := oldest; t < rb.tick; t++ {
+		hash, _ := rb.GetHash(t)
+		if hash != expected[t] {
+			return errors.Wrapf(ErrDesync, "tick %d hash %x != %x", t, hash, expected[t])
+		}
+	}
+	return nil
+}
+
+func (rb *Rollback) simulate(ctx context.Context) error {
+	tick := rb.tick
+	err := rb.registry.Flush()
+	if err != nil {
+		return err
+	}
+
+	// frame is valid only after tick is executed and its hash is written
+	frame := &rb.frames[tick%uint64(len(rb.frames))]
+	frame.valid = false
+	frame.snapshot, err = rb.registry.AppendSnapshot(frame.snapshot[:0])
+	if err != nil {
+		return errors.Wrapf(err, "failed to snapshot tick %d", tick)
+	}
+
+	if rb.inputFn != nil {
+		err = rb.inputFn(rb.registry, tick, rb.inputs[tick])
+		if err != nil {
+			return rb.restoreFailedTick(frame, errors.Wrapf(err, "failed to apply inputs of tick %d", tick))
+		}
+	}
+	err = rb.registry.Tick(rb.deltaTime, ctx)
+	if err != nil {
+		return rb.restoreFailedTick(frame, err)
+	}
+	frame.hash = rb.registry.Hash()
+	frame.tick = tick
+	frame.valid = true
+	rb.tick++
+
+	// inputs older than kept frames are not needed anymore
+	if oldest, found := rb.oldestTick(); found {
+		for t := range rb.inputs {
+			if t < oldest {
+				delete(rb.inputs, t)
+			}
+		}
+	}
+	return nil
+}
+
+// restoreFailedTick restores registry to state before failed tick, so the tick can be executed again
+func (rb *Rollback) restoreFailedTick(frame *rollbackFrame, err error) error {
+	restoreErr := rb.registry.Restore(frame.snapshot)
+	if restoreErr != nil {
+		return errors.Wrapf(restoreErr, "failed to restore tick %d after error %v", rb.tick, err)
+	}
+	return err
+}
+
+func (rb *Rollback) getFrame(tick uint64) *rollbackFrame {
+	// frames after failed re-simulation are not executed in current timeline
+	if tick >= rb.tick {
+		return nil
+	}
+	frame := &rb.frames[tick%uint64(len(rb.frames))]
+	if !frame.valid || frame.tick != tick {
+		return nil
+	}
+	return frame
+}
+
+func (rb *Rollback) oldestTick() (uint64, bool) {
+	if rb.tick == 0 {
+		return 0, false
+	}
+	var oldest uint64
+	if rb.tick > uint64(len(rb.frames)) {
+		oldest = rb.tick - uint64(len(rb.frames))
+	}
+	if rb.getFrame(oldest) == nil {
+		return 0, false
+	}
+	return oldest, true
+}
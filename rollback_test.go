@@ -0,0 +1,140 @@
+package ecsgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type RollbackPos struct {
+	X int64
+}
+
+type RollbackVel struct {
+	V int64
+}
+
+func init() {
+	RegisterComponent[RollbackPos]("RollbackPos")
+	RegisterComponent[RollbackVel]("RollbackVel")
+}
+
+// input sets velocity of entity
+type rollbackInput struct {
+	entity EntityId
+	v      int64
+}
+
+func newRollbackTestRegistry(t *testing.T) (*Registry, EntityId) {
+	r := NewRegistry()
+	sys := r.AddSystem("move", 0, func(ctx *ExecutionContext) error {
+		return ctx.GetQueryResult(0).ForeachEntities(func(accessor *ArcheTypeAccessor) error {
+			pos := GetComponentByAccessor[RollbackPos](accessor)
+			vel := GetComponentByAccessor[RollbackVel](accessor)
+			pos.X += vel.V
+			return nil
+		})
+	})
+	q := sys.NewQuery()
+	AddReadWriteComponent[RollbackPos](q)
+	AddReadonlyComponent[RollbackVel](q)
+
+	e, err := r.CreateEntityImmediate()
+	assert.NoError(t, err)
+	assert.NoError(t, AddComponentImmediate(r, e, RollbackPos{}))
+	assert.NoError(t, AddComponentImmediate(r, e, RollbackVel{V: 1}))
+	return r, e
+}
+
+func applyRollbackInputs(r *Registry, tick uint64, inputs []any) error {
+	for _, input := range inputs {
+		in := input.(rollbackInput)
+		AddComponent(r, in.entity, RollbackVel{V: in.v})
+	}
+	return nil
+}
+
+func TestRollback(t *testing.T) {
+	ctx := context.Background()
+
+	// reference simulation that knows all inputs in time
+	ref, refEntity := newRollbackTestRegistry(t)
+	refRb := NewRollback(ref, 8, time.Millisecond, applyRollbackInputs)
+	assert.NoError(t, refRb.AddInput(3, rollbackInput{entity: refEntity, v: 10}))
+	assert.NoError(t, refRb.AddInput(6, rollbackInput{entity: refEntity, v: -2}))
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, refRb.Tick(ctx))
+	}
+
+	r, e := newRollbackTestRegistry(t)
+	rb := NewRollback(r, 8, time.Millisecond, applyRollbackInputs)
+	for i := 0; i < 8; i++ {
+		assert.NoError(t, rb.Tick(ctx))
+	}
+	assert.Equal(t, int64(8), GetEntityComponent[RollbackPos](r, e).X)
+
+	// late inputs
+	assert.NoError(t, rb.AddInput(6, rollbackInput{entity: e, v: -2}))
+	assert.NoError(t, rb.AddInput(3, rollbackInput{entity: e, v: 10}))
+	assert.NoError(t, rb.Tick(ctx))
+	assert.NoError(t, rb.Tick(ctx))
+	assert.Equal(t, uint64(10), rb.GetTick())
+
+	assert.Equal(t, *GetEntityComponent[RollbackPos](ref, refEntity), *GetEntityComponent[RollbackPos](r, e))
+	for tick := uint64(2); tick < 10; tick++ {
+		hash, found := rb.GetHash(tick)
+		assert.True(t, found)
+		refHash, _ := refRb.GetHash(tick)
+		assert.Equal(t, refHash, hash, "tick %d", tick)
+	}
+
+	assert.NoError(t, rb.Verify(ctx))
+	assert.Equal(t, uint64(10), rb.GetTick())
+
+	// older than kept snapshots
+	assert.ErrorIs(t, rb.AddInput(1, rollbackInput{entity: e, v: 1}), ErrRollbackTooOld)
+}
+
+func TestRollbackFailedTick(t *testing.T) {
+	ctx := context.Background()
+	r, e := newRollbackTestRegistry(t)
+	errTick := errors.New("tick error")
+	var fail bool
+	r.AddSystem("fail", 0, func(ctx *ExecutionContext) error {
+		if fail {
+			return errTick
+		}
+		return nil
+	})
+	rb := NewRollback(r, 8, time.Millisecond, applyRollbackInputs)
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, rb.Tick(ctx))
+	}
+
+	// frame of failed tick has no hash
+	fail = true
+	assert.ErrorContains(t, rb.Tick(ctx), "tick error")
+	assert.Equal(t, uint64(4), rb.GetTick())
+	_, found := rb.GetHash(4)
+	assert.False(t, found)
+	// registry is restored to state before failed tick
+	assert.Equal(t, int64(4), GetEntityComponent[RollbackPos](r, e).X)
+
+	// frames after failed re-simulation are not kept
+	assert.NoError(t, rb.AddInput(1, rollbackInput{entity: e, v: 2}))
+	assert.ErrorContains(t, rb.Tick(ctx), "tick error")
+	assert.Equal(t, uint64(1), rb.GetTick())
+	for tick := uint64(1); tick < 4; tick++ {
+		_, found = rb.GetHash(tick)
+		assert.False(t, found, "tick %d", tick)
+	}
+
+	fail = false
+	assert.NoError(t, rb.Tick(ctx))
+	_, found = rb.GetHash(1)
+	assert.True(t, found)
+	assert.NoError(t, rb.Verify(ctx))
+}
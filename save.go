@@ -0,0 +1,168 @@
+package ecsgo
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"slices"
+
+	"github.com/pkg/errors"
+)
+
+const saveFormatVersion = 1
+
+type savedWorld struct {
+	Version    int           `json:"version"`
+	LastId     uint32        `json:"lastId"`
+	Tombstones []EntityId    `json:"tombstones,omitempty"`
+	Entities   []savedEntity `json:"entities"`
+}
+
+type savedEntity struct {
+	Id         EntityId                   `json:"id"`
+	Components map[string]json.RawMessage `json:"components,omitempty"`
+}
+
+// Save writes all entities and their components to w as JSON.
+// Component types should be registered by RegisterComponent, transient components are skipped.
+// Pending deferred actions are not saved.
+func (r *Registry) Save(w io.Writer) error {
+	if r.isTicking() {
+		return ErrTickInProgress
+	}
+
+	r.issueReservedIds()
+	r.mx.Lock()
+	world := savedWorld{
+		Version:    saveFormatVersion,
+		LastId:     r.lastId,
+		Tombstones: slices.Clone(r.tombstones),
+	}
+	r.mx.Unlock()
+
+	// walk entities in id order to make stable output
+	for id, rec := range r.entities.records {
+		if !rec.alive {
+			continue
+		}
+		entity := savedEntity{
+			Id: EntityId{id: uint32(id), version: rec.version},
+		}
+		if rec.archeType != nil {
+			entity.Components = make(map[string]json.RawMessage)
+			for _, ty := range rec.archeType.getComponentTypeList() {
+				ct := getComponentType(ty)
+				if ct == nil {
+					return errors.Errorf("component %v is not registered", ty)
+				}
+				if ct.transient {
+					continue
+				}
+				data, err := json.Marshal(ct.getValue(rec.archeType, rec.row))
+				if err != nil {
+					return errors.Wrapf(err, "failed to marshal component %s of entity %v", ct.name, entity.Id)
+				}
+				entity.Components[ct.name] = data
+			}
+		}
+		world.Entities = append(world.Entities, entity)
+	}
+
+	return json.NewEncoder(w).Encode(&world)
+}
+
+// Load replaces all entities by data that is written by Save.
+// Pending deferred actions are dropped and observers are not called.
+// Registry is not changed if data can't be decoded.
+func (r *Registry) Load(rd io.Reader) error {
+	if r.isTicking() {
+		return ErrTickInProgress
+	}
+
+	var world savedWorld
+	err := json.NewDecoder(rd).Decode(&world)
+	if err != nil {
+		return errors.Wrap(err, "failed to decode saved data")
+	}
+	if world.Version != saveFormatVersion {
+		return errors.Errorf("unsupported save format version %d", world.Version)
+	}
+
+	// check entity ids, resolve component types and decode values before changing registry
+	checker := newEntityIdChecker(world.LastId)
+	err = checker.check(world.Tombstones...)
+	if err != nil {
+		return err
+	}
+	for _, entity := range world.Entities {
+		err = checker.check(entity.Id)
+		if err != nil {
+			return err
+		}
+	}
+	err = checker.checkAll()
+	if err != nil {
+		return err
+	}
+
+	entityTypes := make([][]*ComponentType, len(world.Entities))
+	entityValues := make([][]any, len(world.Entities))
+	for i, entity := range world.Entities {
+		for name, data := range entity.Components {
+			ct := getComponentTypeByName(name)
+			if ct == nil {
+				return errors.Errorf("component %s of entity %v is not registered", name, entity.Id)
+			}
+			ptr := ct.newValue()
+			err = json.Unmarshal(data, ptr)
+			if err != nil {
+				return errors.Wrapf(err, "failed to unmarshal component %s of entity %v", ct.name, entity.Id)
+			}
+			entityTypes[i] = append(entityTypes[i], ct)
+			entityValues[i] = append(entityValues[i], ptr)
+		}
+	}
+
+	r.reset()
+	r.mx.Lock()
+	r.lastId = world.LastId
+	r.tombstones = slices.Clone(world.Tombstones)
+	r.mx.Unlock()
+	for _, entityId := range world.Tombstones {
+		r.entities.setRemoved(entityId)
+	}
+
+	for i, entity := range world.Entities {
+		r.entities.issue(entity.Id)
+		cts := entityTypes[i]
+		if len(cts) == 0 {
+			continue
+		}
+		types := make([]reflect.Type, len(cts))
+		for j, ct := range cts {
+			types[j] = ct.ty
+		}
+		a := r.getOrMakeArcheTypeSync(types)
+		idx := a.addEntity(entity.Id)
+		for j, ct := range cts {
+			ct.setValue(a, idx, entityValues[i][j])
+		}
+	}
+	return nil
+}
+
+// reset removes all entities and pending entity actions, archetypes and systems are kept
+func (r *Registry) reset() {
+	for _, a := range r.archeTypeList {
+		a.clear()
+	}
+	r.entities.reset()
+	r.deferredActions.resetEntityActions()
+
+	r.mx.Lock()
+	r.lastId = 0
+	r.tombstones = r.tombstones[:0]
+	clear(r.reserved)
+	r.reservedCount.Store(0)
+	r.mx.Unlock()
+}
@@ -0,0 +1,149 @@
+package ecsgo
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type SavePosition struct {
+	X, Y float64
+}
+
+type SaveName struct {
+	Name string
+	Tags []string
+}
+
+type SaveRenderCache struct {
+	Dirty bool
+}
+
+func init() {
+	RegisterComponent[SavePosition]("SavePosition")
+	RegisterComponent[SaveName]("SaveName")
+	RegisterComponent[SaveRenderCache]("SaveRenderCache").SetTransient(true)
+}
+
+func makeSaveTestRegistry(t *testing.T) (*Registry, []EntityId) {
+	r := NewRegistry()
+	var entities []EntityId
+	for i := 0; i < 10; i++ {
+		e := r.CreateEntity()
+		AddComponent(r, e, SavePosition{X: float64(i), Y: float64(i * 2)})
+		if i%2 == 0 {
+			AddComponent(r, e, SaveName{Name: "even", Tags: []string{"a", "b"}})
+		}
+		if i%3 == 0 {
+			AddComponent(r, e, SaveRenderCache{Dirty: true})
+		}
+		entities = append(entities, e)
+	}
+	// entity without component
+	entities = append(entities, r.CreateEntity())
+	assert.NoError(t, r.Flush())
+	assert.NoError(t, r.RemoveEntityImmediate(entities[3]))
+	return r, entities
+}
+
+func TestSaveLoad(t *testing.T) {
+	r, entities := makeSaveTestRegistry(t)
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.Save(&buf))
+
+	var count int
+	loaded := NewRegistry()
+	sys := loaded.AddSystem("count", 0, func(ctx *ExecutionContext) error {
+		count = 0
+		return ctx.GetQueryResult(0).ForeachEntities(func(accessor *ArcheTypeAccessor) error {
+			count++
+			return nil
+		})
+	})
+	AddReadonlyComponent[SavePosition](sys.NewQuery())
+	assert.NoError(t, loaded.Load(bytes.NewReader(buf.Bytes())))
+
+	for i, e := range entities {
+		if i == 3 {
+			assert.True(t, loaded.IsStale(e))
+			continue
+		}
+		assert.True(t, loaded.IsActiveEntity(e))
+		if i == 10 {
+			assert.Nil(t, GetEntityComponent[SavePosition](loaded, e))
+			continue
+		}
+		assert.Equal(t, *GetEntityComponent[SavePosition](r, e), *GetEntityComponent[SavePosition](loaded, e))
+		if i%2 == 0 {
+			assert.Equal(t, SaveName{Name: "even", Tags: []string{"a", "b"}}, *GetEntityComponent[SaveName](loaded, e))
+		}
+		// transient is not saved
+		assert.Nil(t, GetEntityComponent[SaveRenderCache](loaded, e))
+	}
+
+	assert.NoError(t, loaded.Tick(time.Second, context.Background()))
+	assert.Equal(t, 9, count)
+
+	// removed id is reused as same as original registry
+	e1, err := r.CreateEntityImmediate()
+	assert.NoError(t, err)
+	e2, err := loaded.CreateEntityImmediate()
+	assert.NoError(t, err)
+	assert.Equal(t, e1, e2)
+
+	// saved data is stable
+	var buf2, buf3 bytes.Buffer
+	assert.NoError(t, loaded.Save(&buf2))
+	assert.NoError(t, loaded.Load(bytes.NewReader(buf2.Bytes())))
+	assert.NoError(t, loaded.Save(&buf3))
+	assert.Equal(t, buf2.String(), buf3.String())
+}
+
+func TestSaveUnregisteredComponent(t *testing.T) {
+	r := NewRegistry()
+	e := r.CreateEntity()
+	AddComponent(r, e, TestComponent3{})
+	assert.NoError(t, r.Flush())
+
+	var buf bytes.Buffer
+	assert.Error(t, r.Save(&buf))
+	assert.Error(t, r.Load(bytes.NewReader([]byte(`{"version":1,"entities":[{"id":"1:1","components":{"Unknown":{}}}]}`))))
+}
+
+func TestLoadInvalidValue(t *testing.T) {
+	r, entities := makeSaveTestRegistry(t)
+	before := r.Hash()
+
+	// second entity has invalid value, so nothing is loaded
+	data := `{"version":1,"lastId":2,"entities":[` +
+		`{"id":"1:1","components":{"SavePosition":{"X":1,"Y":2}}},` +
+		`{"id":"2:1","components":{"SavePosition":{"X":"invalid"}}}]}`
+	assert.ErrorContains(t, r.Load(bytes.NewReader([]byte(data))), "SavePosition")
+	assert.Equal(t, before, r.Hash())
+	assert.Equal(t, 0.0, GetEntityComponent[SavePosition](r, entities[0]).X)
+	assert.Equal(t, 9.0, GetEntityComponent[SavePosition](r, entities[9]).X)
+}
+
+func TestLoadInvalidEntityIds(t *testing.T) {
+	r, _ := makeSaveTestRegistry(t)
+	before := r.Hash()
+
+	for _, data := range []string{
+		// id that is not issued
+		`{"version":1,"lastId":1,"entities":[{"id":"4294967295:1"}]}`,
+		// nil id
+		`{"version":1,"lastId":1,"entities":[{"id":"0:0"}]}`,
+		// duplicated id
+		`{"version":1,"lastId":2,"entities":[{"id":"1:1"},{"id":"1:2"}]}`,
+		`{"version":1,"lastId":1,"tombstones":["1:1"],"entities":[{"id":"1:2"}]}`,
+		// missing id
+		`{"version":1,"lastId":4294967295,"entities":[{"id":"1:1"}]}`,
+	} {
+		assert.Error(t, r.Load(bytes.NewReader([]byte(data))), data)
+		assert.Equal(t, before, r.Hash(), data)
+	}
+}
@@ -0,0 +1,448 @@
+package ecsgo
+
+import (
+	"encoding/binary"
+	"reflect"
+	"slices"
+	"strings"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	snapshotMagic   = "ECSS"
+	snapshotVersion = 1
+
+	columnPOD   = 0
+	columnCodec = 1
+)
+
+// Snapshot captures whole registry in compact binary, it is same as AppendSnapshot(nil)
+func (r *Registry) Snapshot() ([]byte, error) {
+	return r.AppendSnapshot(nil)
+}
+
+// AppendSnapshot appends binary snapshot of registry to buf, buf can be reused to avoid allocation.
+// Components are written column-wise per archetype, plain old data components are copied by memory
+// so snapshot can be restored only by same build. Component types should be registered by RegisterComponent,
+// transient components are skipped and pending deferred actions are not captured.
+func (r *Registry) AppendSnapshot(buf []byte) ([]byte, error) {
+	if r.isTicking() {
+		return buf, ErrTickInProgress
+	}
+
+	buf = append(buf, snapshotMagic...)
+	buf = binary.LittleEndian.AppendUint32(buf, snapshotVersion)
+
+	r.issueReservedIds()
+	r.mx.Lock()
+	buf = binary.LittleEndian.AppendUint32(buf, r.lastId)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(r.tombstones)))
+	for _, entityId := range r.tombstones {
+		buf = binary.LittleEndian.AppendUint64(buf, entityId.Uint64())
+	}
+	r.mx.Unlock()
+
+	var archeTypeCount uint32
+	for _, a := range r.archeTypeList {
+		if a.getEntityCount() > 0 {
+			archeTypeCount++
+		}
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, archeTypeCount)
+
+	var err error
+	for _, a := range r.archeTypeList {
+		if a.getEntityCount() == 0 {
+			continue
+		}
+		buf, err = appendArcheTypeSnapshot(buf, a)
+		if err != nil {
+			return buf, err
+		}
+	}
+
+	// entities without component
+	var noComponentIds []EntityId
+	for id, rec := range r.entities.records {
+		if rec.alive && rec.archeType == nil {
+			noComponentIds = append(noComponentIds, EntityId{id: uint32(id), version: rec.version})
+		}
+	}
+	buf = appendEntityIds(buf, noComponentIds)
+	return buf, nil
+}
+
+func appendArcheTypeSnapshot(buf []byte, a *ArcheType) ([]byte, error) {
+	var cts []*ComponentType
+	for _, ty := range a.getComponentTypeList() {
+		ct := getComponentType(ty)
+		if ct == nil {
+			return buf, errors.Errorf("component %v is not registered", ty)
+		}
+		if ct.transient {
+			continue
+		}
+		if !ct.pod && ct.codec == nil {
+			return buf, errors.Errorf("component %s is not plain old data and has no codec", ct.name)
+		}
+		cts = append(cts, ct)
+	}
+	slices.SortFunc(cts, func(a, b *ComponentType) int {
+		return strings.Compare(a.name, b.name)
+	})
+
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(cts)))
+	for _, ct := range cts {
+		buf = appendString(buf, ct.name)
+	}
+	buf = appendEntityIds(buf, a.enitityIds)
+
+	var err error
+	for _, ct := range cts {
+		if ct.pod {
+			buf = append(buf, columnPOD)
+			lenPos := len(buf)
+			buf = binary.LittleEndian.AppendUint64(buf, 0)
+			buf = ct.appendColumn(buf, a)
+			binary.LittleEndian.PutUint64(buf[lenPos:], uint64(len(buf)-lenPos-8))
+			continue
+		}
+
+		buf = append(buf, columnCodec)
+		for idx := range a.enitityIds {
+			lenPos := len(buf)
+			buf = binary.LittleEndian.AppendUint32(buf, 0)
+			buf, err = ct.codec.AppendComponent(buf, ct.getValue(a, idx))
+			if err != nil {
+				return buf, errors.Wrapf(err, "failed to encode component %s of entity %v", ct.name, a.enitityIds[idx])
+			}
+			binary.LittleEndian.PutUint32(buf[lenPos:], uint32(len(buf)-lenPos-4))
+		}
+	}
+	return buf, nil
+}
+
+// Restore replaces all entities by snapshot that is captured by Snapshot.
+// Pending deferred actions are dropped and observers are not called.
+// Registry is not changed if snapshot is invalid.
+func (r *Registry) Restore(data []byte) error {
+	if r.isTicking() {
+		return ErrTickInProgress
+	}
+
+	rd := &snapshotReader{data: data}
+	if string(rd.bytes(len(snapshotMagic))) != snapshotMagic {
+		return errors.New("invalid snapshot")
+	}
+	if version := rd.uint32(); version != snapshotVersion {
+		return errors.Errorf("unsupported snapshot version %d", version)
+	}
+
+	lastId := rd.uint32()
+	// every id up to lastId is in snapshot, so lastId is bounded by remained length
+	if rd.err == nil && uint64(lastId) > uint64(len(rd.data)-rd.off)/EntityIdBinarySize {
+		return errors.Errorf("invalid last entity id %d", lastId)
+	}
+	checker := newEntityIdChecker(lastId)
+	tombstones := rd.entityIds()
+	if rd.err != nil {
+		return rd.err
+	}
+	err := checker.check(tombstones...)
+	if err != nil {
+		return err
+	}
+
+	// parse all archetypes to temporary archetypes before changing registry
+	var archeTypes []restoredArcheType
+	entities := &entityTable{}
+	archeTypeCount := rd.uint32()
+	for i := uint32(0); i < archeTypeCount && rd.err == nil; i++ {
+		restored, err := parseArcheTypeSnapshot(rd, entities, checker)
+		if err != nil {
+			return err
+		}
+		archeTypes = append(archeTypes, restored)
+	}
+	noComponentIds := rd.entityIds()
+	if rd.err != nil {
+		return rd.err
+	}
+	err = checker.check(noComponentIds...)
+	if err != nil {
+		return err
+	}
+	err = checker.checkAll()
+	if err != nil {
+		return err
+	}
+
+	r.reset()
+	r.mx.Lock()
+	r.lastId = lastId
+	r.tombstones = append(r.tombstones, tombstones...)
+	r.mx.Unlock()
+	for _, entityId := range tombstones {
+		r.entities.setRemoved(entityId)
+	}
+	for _, restored := range archeTypes {
+		src := restored.archeType
+		for _, entityId := range src.enitityIds {
+			r.entities.issue(entityId)
+		}
+		a := r.getOrMakeArcheTypeSync(restored.types)
+		if a == nil {
+			continue
+		}
+		start := a.addEntities(src.enitityIds)
+		for _, cmp := range src.components {
+			if cmp != nil {
+				cmp.copyAllToOtherArcheType(a, start)
+			}
+		}
+	}
+	for _, entityId := range noComponentIds {
+		r.entities.issue(entityId)
+	}
+	return nil
+}
+
+// restoredArcheType is archetype section of snapshot that is parsed but not added to registry yet
+type restoredArcheType struct {
+	types     []reflect.Type
+	archeType *ArcheType
+}
+
+// parseArcheTypeSnapshot parses archetype section to archetype that is not in registry
+func parseArcheTypeSnapshot(rd *snapshotReader, entities *entityTable, checker *entityIdChecker) (restoredArcheType, error) {
+	// name of component has at least its length
+	cts := make([]*ComponentType, rd.count(1))
+	types := make([]reflect.Type, len(cts))
+	for i := range cts {
+		name := rd.string()
+		if rd.err != nil {
+			return restoredArcheType{}, rd.err
+		}
+		cts[i] = getComponentTypeByName(name)
+		if cts[i] == nil {
+			return restoredArcheType{}, errors.Errorf("component %s is not registered", name)
+		}
+		types[i] = cts[i].ty
+	}
+
+	entityIds := rd.entityIds()
+	if rd.err != nil {
+		return restoredArcheType{}, rd.err
+	}
+	err := checker.check(entityIds...)
+	if err != nil {
+		return restoredArcheType{}, err
+	}
+	a := newArcheTypeWithTable(entities, types...)
+	start := a.addEntities(entityIds)
+
+	for _, ct := range cts {
+		switch rd.byte() {
+		case columnPOD:
+			n := rd.uint64()
+			if n > uint64(len(rd.data)-rd.off) {
+				return restoredArcheType{}, errors.New("snapshot is truncated")
+			}
+			data := rd.bytes(int(n))
+			if rd.err != nil {
+				return restoredArcheType{}, rd.err
+			}
+			err := ct.restoreColumn(a, start, data)
+			if err != nil {
+				return restoredArcheType{}, errors.Wrapf(err, "failed to restore component %s", ct.name)
+			}
+		case columnCodec:
+			if ct.codec == nil {
+				return restoredArcheType{}, errors.Errorf("component %s has no codec", ct.name)
+			}
+			for i := range entityIds {
+				data := rd.bytes(int(rd.uint32()))
+				if rd.err != nil {
+					return restoredArcheType{}, rd.err
+				}
+				ptr := ct.newValue()
+				err := ct.codec.DecodeComponent(data, ptr)
+				if err != nil {
+					return restoredArcheType{}, errors.Wrapf(err, "failed to decode component %s of entity %v", ct.name, entityIds[i])
+				}
+				ct.setValue(a, start+i, ptr)
+			}
+		default:
+			if rd.err != nil {
+				return restoredArcheType{}, rd.err
+			}
+			return restoredArcheType{}, errors.Errorf("invalid column of component %s", ct.name)
+		}
+	}
+	return restoredArcheType{types: types, archeType: a}, nil
+}
+
+// entityIdChecker checks entity ids of snapshot or saved data before they are written to entity table
+type entityIdChecker struct {
+	lastId uint32
+	seen   map[uint32]struct{}
+}
+
+func newEntityIdChecker(lastId uint32) *entityIdChecker {
+	return &entityIdChecker{
+		lastId: lastId,
+		seen:   make(map[uint32]struct{}),
+	}
+}
+
+// check returns error if entity id is not issued by registry or it is duplicated
+func (c *entityIdChecker) check(entityIds ...EntityId) error {
+	for _, entityId := range entityIds {
+		if !entityId.NotNil() || entityId.id > c.lastId {
+			return errors.Errorf("invalid entity id %v, last entity id is %d", entityId, c.lastId)
+		}
+		if _, found := c.seen[entityId.id]; found {
+			return errors.Errorf("entity id %v is duplicated", entityId)
+		}
+		c.seen[entityId.id] = struct{}{}
+	}
+	return nil
+}
+
+// checkAll returns error if some ids are missing, every issued id is alive or in tombstones
+func (c *entityIdChecker) checkAll() error {
+	if len(c.seen) != int(c.lastId) {
+		return errors.Errorf("%d of %d entity ids are missing", int(c.lastId)-len(c.seen), c.lastId)
+	}
+	return nil
+}
+
+func appendPODColumn[T any](buf []byte, a *ArcheType) []byte {
+	cmpData := getCompData[T](a)
+	if cmpData == nil || len(cmpData.arr) == 0 {
+		return buf
+	}
+	size := int(unsafe.Sizeof(cmpData.arr[0]))
+	return append(buf, unsafe.Slice((*byte)(unsafe.Pointer(&cmpData.arr[0])), len(cmpData.arr)*size)...)
+}
+
+func restorePODColumn[T any](a *ArcheType, start int, data []byte) error {
+	cmpData := getCompData[T](a)
+	if cmpData == nil {
+		return errors.New("archetype doesn't have component")
+	}
+	var t T
+	size := int(unsafe.Sizeof(t))
+	n := len(cmpData.arr) - start
+	if len(data) != n*size {
+		return errors.Errorf("column size mismatch %d != %d", len(data), n*size)
+	}
+	if n == 0 || size == 0 {
+		return nil
+	}
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(&cmpData.arr[start])), n*size), data)
+	return nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendEntityIds(buf []byte, entityIds []EntityId) []byte {
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(entityIds)))
+	for _, entityId := range entityIds {
+		buf = binary.LittleEndian.AppendUint64(buf, entityId.Uint64())
+	}
+	return buf
+}
+
+// snapshotReader reads binary and keeps first error, zero value is returned after error
+type snapshotReader struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func (rd *snapshotReader) bytes(n int) []byte {
+	if rd.err != nil {
+		return nil
+	}
+	// compare with remained length, rd.off+n can overflow when n is corrupted
+	if n < 0 || n > len(rd.data)-rd.off {
+		rd.err = errors.New("snapshot is truncated")
+		return nil
+	}
+	b := rd.data[rd.off : rd.off+n]
+	rd.off += n
+	return b
+}
+
+func (rd *snapshotReader) byte() byte {
+	b := rd.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (rd *snapshotReader) uint32() uint32 {
+	b := rd.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (rd *snapshotReader) uint64() uint64 {
+	b := rd.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+func (rd *snapshotReader) string() string {
+	if rd.err != nil {
+		return ""
+	}
+	n, size := binary.Uvarint(rd.data[rd.off:])
+	if size <= 0 {
+		rd.err = errors.New("snapshot is truncated")
+		return ""
+	}
+	rd.off += size
+	if n > uint64(len(rd.data)-rd.off) {
+		rd.err = errors.New("snapshot is truncated")
+		return ""
+	}
+	return string(rd.bytes(int(n)))
+}
+
+// count reads number of elements that are at least elemSize bytes,
+// it fails if remained data is too short so corrupted count doesn't allocate huge memory
+func (rd *snapshotReader) count(elemSize int) int {
+	n := int(rd.uint32())
+	if rd.err != nil {
+		return 0
+	}
+	if n*elemSize > len(rd.data)-rd.off {
+		rd.err = errors.New("snapshot is truncated")
+		return 0
+	}
+	return n
+}
+
+func (rd *snapshotReader) entityIds() []EntityId {
+	n := rd.count(8)
+	if rd.err != nil {
+		return nil
+	}
+	entityIds := make([]EntityId, n)
+	for i := range entityIds {
+		entityIds[i] = EntityIdFromUint64(rd.uint64())
+	}
+	return entityIds
+}
@@ -0,0 +1,265 @@
+package ecsgo
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type SnapPosition struct {
+	X, Y float32
+	Grid [2]int16
+}
+
+type SnapVelocity struct {
+	V float64
+}
+
+type SnapTag struct{}
+
+// SnapLabel has string so it needs codec
+type SnapLabel struct {
+	Label string
+}
+
+type snapLabelCodec struct{}
+
+func (snapLabelCodec) AppendComponent(buf []byte, ptr any) ([]byte, error) {
+	return append(buf, ptr.(*SnapLabel).Label...), nil
+}
+
+func (snapLabelCodec) DecodeComponent(data []byte, ptr any) error {
+	ptr.(*SnapLabel).Label = string(data)
+	return nil
+}
+
+// SnapPath implements encoding.BinaryMarshaler
+type SnapPath struct {
+	Points []int32
+}
+
+func (p *SnapPath) MarshalBinary() ([]byte, error) {
+	var buf []byte
+	for _, v := range p.Points {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(v))
+	}
+	return buf, nil
+}
+
+func (p *SnapPath) UnmarshalBinary(data []byte) error {
+	p.Points = nil
+	for i := 0; i+4 <= len(data); i += 4 {
+		p.Points = append(p.Points, int32(binary.LittleEndian.Uint32(data[i:])))
+	}
+	return nil
+}
+
+type SnapNoCodec struct {
+	Values map[string]int
+}
+
+func init() {
+	RegisterComponent[SnapPosition]("SnapPosition")
+	RegisterComponent[SnapVelocity]("SnapVelocity")
+	RegisterComponent[SnapTag]("SnapTag")
+	RegisterComponent[SnapLabel]("SnapLabel").SetCodec(snapLabelCodec{})
+	RegisterComponent[SnapPath]("SnapPath")
+	RegisterComponent[SnapNoCodec]("SnapNoCodec")
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	r := NewRegistry()
+	var entities []EntityId
+	for i := 0; i < 20; i++ {
+		e := r.CreateEntity()
+		AddComponent(r, e, SnapPosition{X: float32(i), Y: -float32(i), Grid: [2]int16{int16(i), 1}})
+		if i%2 == 0 {
+			AddComponent(r, e, SnapVelocity{V: float64(i) / 2})
+			AddComponent(r, e, SnapTag{})
+		}
+		if i%3 == 0 {
+			AddComponent(r, e, SnapLabel{Label: "label"})
+			AddComponent(r, e, SnapPath{Points: []int32{int32(i), -1}})
+		}
+		entities = append(entities, e)
+	}
+	entities = append(entities, r.CreateEntity())
+	assert.NoError(t, r.Flush())
+	assert.NoError(t, r.RemoveEntityImmediate(entities[5]))
+
+	snapshot, err := r.Snapshot()
+	assert.NoError(t, err)
+
+	// change world after snapshot
+	for _, e := range entities[:4] {
+		assert.NoError(t, AddComponentImmediate(r, e, SnapPosition{X: 1000}))
+	}
+	created, err := r.CreateEntityImmediate()
+	assert.NoError(t, err)
+	assert.NoError(t, r.RemoveEntityImmediate(entities[7]))
+
+	check := func(restored *Registry) {
+		for i, e := range entities {
+			if i == 5 {
+				assert.True(t, restored.IsStale(e))
+				continue
+			}
+			assert.True(t, restored.IsActiveEntity(e), "entity %v", e)
+			if i == 20 {
+				assert.Nil(t, GetEntityComponent[SnapPosition](restored, e))
+				continue
+			}
+			assert.Equal(t, SnapPosition{X: float32(i), Y: -float32(i), Grid: [2]int16{int16(i), 1}}, *GetEntityComponent[SnapPosition](restored, e))
+			if i%2 == 0 {
+				assert.Equal(t, float64(i)/2, GetEntityComponent[SnapVelocity](restored, e).V)
+				assert.NotNil(t, GetEntityComponent[SnapTag](restored, e))
+			}
+			if i%3 == 0 {
+				assert.Equal(t, "label", GetEntityComponent[SnapLabel](restored, e).Label)
+				assert.Equal(t, []int32{int32(i), -1}, GetEntityComponent[SnapPath](restored, e).Points)
+			}
+		}
+	}
+
+	assert.NoError(t, r.Restore(snapshot))
+	check(r)
+	// entity that is created after snapshot is not issued
+	assert.False(t, r.IsActiveEntity(created))
+	recreated, err := r.CreateEntityImmediate()
+	assert.NoError(t, err)
+	assert.Equal(t, created, recreated)
+
+	fresh := NewRegistry()
+	assert.NoError(t, fresh.Restore(snapshot))
+	check(fresh)
+
+	// snapshot of restored registry is same
+	again, err := fresh.AppendSnapshot(make([]byte, 0, len(snapshot)))
+	assert.NoError(t, err)
+	assert.Equal(t, snapshot, again)
+
+	assert.Error(t, fresh.Restore(snapshot[:len(snapshot)-3]))
+}
+
+func TestSnapshotNeedsCodec(t *testing.T) {
+	r := NewRegistry()
+	e := r.CreateEntity()
+	AddComponent(r, e, SnapNoCodec{})
+	assert.NoError(t, r.Flush())
+
+	_, err := r.Snapshot()
+	assert.Error(t, err)
+}
+
+func BenchmarkSnapshotRestore(b *testing.B) {
+	r := NewRegistry()
+	for i := 0; i < 10000; i++ {
+		e := r.CreateEntity()
+		AddComponent(r, e, SnapPosition{X: float32(i)})
+		AddComponent(r, e, SnapVelocity{V: float64(i)})
+	}
+	r.Flush()
+
+	buf, _ := r.Snapshot()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf, _ = r.AppendSnapshot(buf[:0])
+		r.Restore(buf)
+	}
+}
+
+func TestRestoreCorruptSnapshot(t *testing.T) {
+	header := func(lastId uint32) []byte {
+		buf := []byte(snapshotMagic)
+		buf = binary.LittleEndian.AppendUint32(buf, snapshotVersion)
+		return binary.LittleEndian.AppendUint32(buf, lastId)
+	}
+
+	// huge tombstone count
+	data := binary.LittleEndian.AppendUint32(header(0), 0xFFFFFFFF)
+	assert.ErrorContains(t, NewRegistry().Restore(data), "truncated")
+
+	// huge component count of archetype
+	data = binary.LittleEndian.AppendUint32(header(0), 0)
+	data = binary.LittleEndian.AppendUint32(data, 1)
+	data = binary.LittleEndian.AppendUint32(data, 0xFFFFFFFF)
+	assert.ErrorContains(t, NewRegistry().Restore(data), "truncated")
+	_, err := DiffSnapshots(data, data)
+	assert.Error(t, err)
+
+	// huge length of component name
+	data = binary.LittleEndian.AppendUint32(header(0), 0)
+	data = binary.LittleEndian.AppendUint32(data, 1)
+	data = binary.LittleEndian.AppendUint32(data, 1)
+	data = binary.AppendUvarint(data, 1<<63-1)
+	data = append(data, "SnapPosition"...)
+	assert.ErrorContains(t, NewRegistry().Restore(data), "truncated")
+
+	// huge length of column
+	data = binary.LittleEndian.AppendUint32(header(1), 0)
+	data = binary.LittleEndian.AppendUint32(data, 1)
+	data = binary.LittleEndian.AppendUint32(data, 1)
+	data = appendString(data, "SnapPosition")
+	data = appendEntityIds(data, []EntityId{{id: 1, version: 1}})
+	data = append(data, columnPOD)
+	data = binary.LittleEndian.AppendUint64(data, 1<<63-1)
+	data = append(data, make([]byte, 64)...)
+	assert.ErrorContains(t, NewRegistry().Restore(data), "truncated")
+
+	// every truncated snapshot fails without panic
+	r := NewRegistry()
+	for i := 0; i < 3; i++ {
+		e := r.CreateEntity()
+		AddComponent(r, e, SnapPosition{X: float32(i)})
+		AddComponent(r, e, SnapLabel{Label: "label"})
+	}
+	assert.NoError(t, r.Flush())
+	snapshot, err := r.Snapshot()
+	assert.NoError(t, err)
+	for n := 0; n < len(snapshot); n++ {
+		assert.Error(t, NewRegistry().Restore(snapshot[:n]), "length %d", n)
+	}
+	assert.NoError(t, NewRegistry().Restore(snapshot))
+
+	// registry is not changed by invalid snapshot
+	other := NewRegistry()
+	e := other.CreateEntity()
+	AddComponent(other, e, SnapPosition{X: 10})
+	assert.NoError(t, other.Flush())
+	hash := other.Hash()
+	for n := len(snapshot) / 2; n < len(snapshot); n++ {
+		assert.Error(t, other.Restore(snapshot[:n]), "length %d", n)
+		assert.Equal(t, hash, other.Hash(), "length %d", n)
+	}
+	assert.True(t, other.IsActiveEntity(e))
+}
+
+func TestRestoreInvalidEntityIds(t *testing.T) {
+	snapshot := func(lastId uint32, tombstones, entityIds []EntityId) []byte {
+		buf := []byte(snapshotMagic)
+		buf = binary.LittleEndian.AppendUint32(buf, snapshotVersion)
+		buf = binary.LittleEndian.AppendUint32(buf, lastId)
+		buf = appendEntityIds(buf, tombstones)
+		buf = binary.LittleEndian.AppendUint32(buf, 0)
+		return appendEntityIds(buf, entityIds)
+	}
+
+	r := NewRegistry()
+	assert.NoError(t, r.Restore(snapshot(3, []EntityId{{id: 2, version: 1}}, []EntityId{{id: 1, version: 1}, {id: 3, version: 2}})))
+	assert.True(t, r.IsActiveEntity(EntityId{id: 3, version: 2}))
+
+	// nil id
+	assert.ErrorContains(t, r.Restore(snapshot(1, nil, []EntityId{{}})), "invalid entity id")
+	// id that is not issued
+	assert.ErrorContains(t, r.Restore(snapshot(1, nil, []EntityId{{id: 2, version: 1}})), "invalid entity id")
+	// huge id with huge last id
+	assert.ErrorContains(t, r.Restore(snapshot(0xFFFFFFFF, nil, []EntityId{{id: 0xFFFFFFFF, version: 1}})), "invalid last entity id")
+	// duplicated id
+	assert.ErrorContains(t, r.Restore(snapshot(2, nil, []EntityId{{id: 1, version: 1}, {id: 1, version: 2}})), "duplicated")
+	assert.ErrorContains(t, r.Restore(snapshot(2, []EntityId{{id: 1, version: 1}}, []EntityId{{id: 1, version: 2}})), "duplicated")
+	// missing id
+	assert.ErrorContains(t, r.Restore(snapshot(3, nil, []EntityId{{id: 1, version: 1}, {id: 2, version: 1}})), "missing")
+
+	assert.True(t, r.IsActiveEntity(EntityId{id: 3, version: 2}))
+}
@@ -1,21 +1,27 @@
 package ecsgo
 
 import (
+	"context"
 	"reflect"
 	"slices"
+	"sync"
 	"time"
 )
 
 type ExecutionContext struct {
 	registry  *Registry
 	deltaTime time.Duration
+	// context is context that is passed to Tick
+	context context.Context
 
 	queryResults []*QueryResult
+	system       *System
 }
 
 type QueryResult struct {
 	query         *Query
 	archeTypeList []*ArcheType
+	ctx           *ExecutionContext
 }
 
 // Component Query
@@ -36,9 +42,18 @@ type System struct {
 	name     string
 	priority int
 	fn       SystemFn
+	// order is registration order in execution group
+	order int
 
 	// query
 	queries []*Query
+
+	// commands recorded while executing, played back by registry at sync point
+	commands *CommandBuffer
+
+	// jobs scheduled by system in current execution, systems that depend on it wait for them
+	jobMx sync.Mutex
+	jobs  []*JobHandle
 }
 
 func newSystem(registry *Registry, name string, priority int, fn SystemFn) *System {
@@ -50,21 +65,41 @@ func newSystem(registry *Registry, name string, priority int, fn SystemFn) *Syst
 	}
 }
 
-func (s *System) execute(deltaTime time.Duration) error {
+func (s *System) execute(deltaTime time.Duration, tickCtx context.Context) error {
+	// jobs of failed execution are left to sync point of Tick
+	s.takeJobs()
 	ctx := &ExecutionContext{
 		registry:  s.registry,
 		deltaTime: deltaTime,
+		context:   tickCtx,
+		system:    s,
 	}
 	for _, q := range s.queries {
 		qr := &QueryResult{
 			query:         q,
 			archeTypeList: q.interestedArcheTypeList,
+			ctx:           ctx,
 		}
 		ctx.queryResults = append(ctx.queryResults, qr)
 	}
 	return s.fn(ctx)
 }
 
+func (s *System) addJob(h *JobHandle) {
+	s.jobMx.Lock()
+	s.jobs = append(s.jobs, h)
+	s.jobMx.Unlock()
+}
+
+// takeJobs returns jobs scheduled in current execution and clears them
+func (s *System) takeJobs() []*JobHandle {
+	s.jobMx.Lock()
+	defer s.jobMx.Unlock()
+	jobs := s.jobs
+	s.jobs = nil
+	return jobs
+}
+
 func (s *System) GetName() string {
 	return s.name
 }
@@ -282,6 +317,27 @@ func (c *ExecutionContext) CreateEntity() EntityId {
 	return c.registry.CreateEntity()
 }
 
+// Commands returns command buffer of executing system, it doesn't need lock to record
+// and is played back in system registration order after all systems are executed
+func (c *ExecutionContext) Commands() *CommandBuffer {
+	if c.system.commands == nil {
+		c.system.commands = newCommandBuffer(c.registry)
+	}
+	return c.system.commands
+}
+
+func (c *ExecutionContext) checkStaleAccess(op string, entityId EntityId, ty reflect.Type) {
+	if c.registry == nil {
+		return
+	}
+	c.registry.reportStaleAccess(op, c.system.name, entityId, ty)
+}
+
+// GetContext returns context that is passed to Tick
+func (c *ExecutionContext) GetContext() context.Context {
+	return c.context
+}
+
 func (c *ExecutionContext) GetDeltaTime() time.Duration {
 	return c.deltaTime
 }
@@ -323,6 +379,7 @@ func (qr *QueryResult) ForeachEntities(fn func(accessor *ArcheTypeAccessor) erro
 
 func GetComponent[T any](c *ExecutionContext, entityId EntityId) *T {
 	var t T
+	c.checkStaleAccess("GetComponent", entityId, reflect.TypeOf(t))
 	for i := 0; i < c.GetQueryResultCount(); i++ {
 		qr := c.GetQueryResult(i)
 		for j := 0; j < qr.GetArcheTypeCount(); j++ {
@@ -343,6 +400,7 @@ func GetComponent[T any](c *ExecutionContext, entityId EntityId) *T {
 
 func HasComponent[T any](c *ExecutionContext, entityId EntityId) bool {
 	var t T
+	c.checkStaleAccess("HasComponent", entityId, reflect.TypeOf(t))
 	for i := 0; i < c.GetQueryResultCount(); i++ {
 		qr := c.GetQueryResult(i)
 		for j := 0; j < qr.GetArcheTypeCount(); j++ {
@@ -0,0 +1,108 @@
+package ecsgo
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// defaultWorkerIdleTimeout stops worker that has no task for this duration,
+// so registry that is dropped without Close doesn't keep goroutines
+const defaultWorkerIdleTimeout = time.Second
+
+// workerPool runs tasks on at most size goroutines that are reused across ticks.
+// Workers are started on demand and stopped when they are idle.
+// Queue is not bounded, so task can submit other tasks without blocking.
+type workerPool struct {
+	size        int
+	idleTimeout time.Duration
+
+	mx    sync.Mutex
+	cond  *sync.Cond
+	tasks []func()
+	// workers is number of running workers, busy is number of workers that are running task
+	workers int
+	busy    int
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// newWorkerPool makes pool with size workers, GOMAXPROCS workers if size is not positive
+func newWorkerPool(size int) *workerPool {
+	if size <= 0 {
+		size = runtime.GOMAXPROCS(0)
+	}
+	p := &workerPool{
+		size:        size,
+		idleTimeout: defaultWorkerIdleTimeout,
+	}
+	p.cond = sync.NewCond(&p.mx)
+	return p
+}
+
+func (p *workerPool) work() {
+	defer p.wg.Done()
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	for {
+		idleSince := time.Now()
+		for len(p.tasks) == 0 && !p.closed {
+			if time.Since(idleSince) >= p.idleTimeout {
+				p.workers--
+				return
+			}
+			timer := time.AfterFunc(p.idleTimeout, p.wakeIdle)
+			p.cond.Wait()
+			timer.Stop()
+		}
+		if len(p.tasks) == 0 {
+			// closed
+			p.workers--
+			return
+		}
+		task := p.tasks[0]
+		p.tasks[0] = nil
+		p.tasks = p.tasks[1:]
+		p.busy++
+		p.mx.Unlock()
+
+		task()
+
+		p.mx.Lock()
+		p.busy--
+	}
+}
+
+// wakeIdle wakes idle workers to check idle timeout
+func (p *workerPool) wakeIdle() {
+	p.mx.Lock()
+	p.cond.Broadcast()
+	p.mx.Unlock()
+}
+
+// submit queues task, it returns false if pool is closed
+func (p *workerPool) submit(task func()) bool {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	if p.closed {
+		return false
+	}
+	p.tasks = append(p.tasks, task)
+	if len(p.tasks) > p.workers-p.busy && p.workers < p.size {
+		// no idle worker takes this task
+		p.workers++
+		p.wg.Add(1)
+		go p.work()
+	}
+	p.cond.Signal()
+	return true
+}
+
+// close stops workers after queued tasks are done
+func (p *workerPool) close() {
+	p.mx.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mx.Unlock()
+	p.wg.Wait()
+}
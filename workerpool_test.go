@@ -0,0 +1,116 @@
+package ecsgo
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerPool(t *testing.T) {
+	r := NewRegistry()
+	r.SetWorkerCount(2)
+	defer r.Close()
+	e, err := r.CreateEntityImmediate()
+	assert.NoError(t, err)
+	assert.NoError(t, AddComponentImmediate(r, e, TestComponent1{}))
+
+	var count, running, maxRunning atomic.Int32
+	for i := 0; i < 200; i++ {
+		sys := r.AddSystem("small", i%3, func(ctx *ExecutionContext) error {
+			n := running.Add(1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			count.Add(1)
+			running.Add(-1)
+			return nil
+		})
+		AddReadonlyComponent[TestComponent1](sys.NewQuery())
+	}
+
+	assert.NoError(t, r.Tick(time.Millisecond, context.Background()))
+	goroutines := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, r.Tick(time.Millisecond, context.Background()))
+	}
+	assert.Equal(t, int32(200*11), count.Load())
+	assert.LessOrEqual(t, maxRunning.Load(), int32(2))
+	// workers are reused
+	assert.LessOrEqual(t, runtime.NumGoroutine(), goroutines)
+
+	// error stops remained systems and next tick runs all again
+	errSystem := errors.New("system error")
+	var fail atomic.Bool
+	fail.Store(true)
+	r.AddSystem("failing", 100, func(ctx *ExecutionContext) error {
+		if fail.Load() {
+			return errSystem
+		}
+		return nil
+	})
+	assert.NoError(t, r.Flush())
+	count.Store(0)
+	assert.ErrorContains(t, r.Tick(time.Millisecond, context.Background()), errSystem.Error())
+	fail.Store(false)
+	count.Store(0)
+	assert.NoError(t, r.Tick(time.Millisecond, context.Background()))
+	assert.Equal(t, int32(200), count.Load())
+
+	// started again after close
+	r.Close()
+	assert.NoError(t, r.Tick(time.Millisecond, context.Background()))
+}
+
+func BenchmarkManySmallSystems(b *testing.B) {
+	r := NewRegistry()
+	defer r.Close()
+	for i := 0; i < 300; i++ {
+		r.AddSystem("small", 0, func(ctx *ExecutionContext) error {
+			return nil
+		})
+	}
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Tick(time.Millisecond, ctx)
+	}
+}
+
+func TestWorkerPoolIdle(t *testing.T) {
+	p := newWorkerPool(4)
+	p.idleTimeout = 10 * time.Millisecond
+	defer p.close()
+	var count atomic.Int32
+	for round := 0; round < 2; round++ {
+		done := make(chan struct{}, 100)
+		for i := 0; i < 100; i++ {
+			p.submit(func() {
+				count.Add(1)
+				done <- struct{}{}
+			})
+		}
+		for i := 0; i < 100; i++ {
+			<-done
+		}
+
+		p.mx.Lock()
+		assert.LessOrEqual(t, p.workers, 4)
+		p.mx.Unlock()
+
+		// idle workers are stopped and started again by next task
+		assert.Eventually(t, func() bool {
+			p.mx.Lock()
+			defer p.mx.Unlock()
+			return p.workers == 0
+		}, time.Second, time.Millisecond)
+	}
+	assert.Equal(t, int32(200), count.Load())
+}
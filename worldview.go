@@ -0,0 +1,205 @@
+package ecsgo
+
+import (
+	"reflect"
+	"sync"
+)
+
+// WorldView is read-only copy of selected components that is published at the end of every Tick.
+// Frames are double buffered, so other goroutine like render thread can read published frame while next Tick runs.
+// Components are copied by value, so slices, maps and pointers in components are shared with registry.
+type WorldView struct {
+	registry *Registry
+	types    map[reflect.Type]bool
+
+	mx    sync.Mutex
+	tick  uint64
+	front *ViewFrame
+	// frames has all frames that are made, frame is reused when it is not front and not acquired
+	frames []*ViewFrame
+}
+
+// ViewFrame is frame of world view at the end of a Tick, it should not be modified.
+// Frame is valid until Release is called.
+type ViewFrame struct {
+	view     *WorldView
+	tick     uint64
+	entities entityTable
+	// archeTypes has copied archetypes in registry archetype order
+	archeTypes []*ArcheType
+	// copied is copied archetype of archetype in registry
+	copied map[*ArcheType]*ArcheType
+	refs   int
+}
+
+// NewWorldView makes world view, components to copy are selected by AddViewComponent
+func (r *Registry) NewWorldView() *WorldView {
+	v := &WorldView{
+		registry: r,
+		types:    make(map[reflect.Type]bool),
+	}
+	r.viewMx.Lock()
+	r.views = append(r.views, v)
+	r.viewMx.Unlock()
+	return v
+}
+
+// AddViewComponent selects T to be copied to frames, it is applied from next published frame
+func AddViewComponent[T any](v *WorldView) {
+	var t T
+	v.mx.Lock()
+	v.types[reflect.TypeOf(t)] = true
+	v.mx.Unlock()
+}
+
+// Acquire returns latest published frame, nil if no frame is published yet.
+// Frame is not reused until Release is called.
+func (v *WorldView) Acquire() *ViewFrame {
+	v.mx.Lock()
+	defer v.mx.Unlock()
+	if v.front == nil {
+		return nil
+	}
+	v.front.refs++
+	return v.front
+}
+
+// Release releases acquired frame
+func (f *ViewFrame) Release() {
+	f.view.mx.Lock()
+	defer f.view.mx.Unlock()
+	if f.refs > 0 {
+		f.refs--
+	}
+}
+
+// GetTick returns number of Ticks that are published before this frame, it starts from 1
+func (f *ViewFrame) GetTick() uint64 {
+	return f.tick
+}
+
+// GetEntityCount returns number of entities that have selected components
+func (f *ViewFrame) GetEntityCount() int {
+	count := 0
+	for _, a := range f.archeTypes {
+		count += a.getEntityCount()
+	}
+	return count
+}
+
+// Foreach calls fn for all entities in frame, accessor only has selected components
+func (f *ViewFrame) Foreach(fn func(accessor *ArcheTypeAccessor) error) error {
+	for _, a := range f.archeTypes {
+		err := a.Foreach(fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetViewComponent returns component of entity in frame, nil if entity doesn't have T or T is not selected
+func GetViewComponent[T any](f *ViewFrame, entityId EntityId) *T {
+	a := f.entities.getArcheType(entityId)
+	if a == nil {
+		return nil
+	}
+	return getArcheTypeComponent[T](a, entityId)
+}
+
+// publish copies selected components to frame that is not used and makes it front
+func (v *WorldView) publish() {
+	v.mx.Lock()
+	var frame *ViewFrame
+	for _, f := range v.frames {
+		if f != v.front && f.refs == 0 {
+			frame = f
+			break
+		}
+	}
+	if frame == nil {
+		frame = &ViewFrame{
+			view:   v,
+			copied: make(map[*ArcheType]*ArcheType),
+		}
+		v.frames = append(v.frames, frame)
+	}
+	types := make(map[reflect.Type]bool, len(v.types))
+	for t := range v.types {
+		types[t] = true
+	}
+	v.tick++
+	tick := v.tick
+	v.mx.Unlock()
+
+	// frame is not visible to readers until it becomes front
+	frame.copyFrom(v.registry, types)
+	frame.tick = tick
+
+	v.mx.Lock()
+	v.front = frame
+	v.mx.Unlock()
+}
+
+func (f *ViewFrame) copyFrom(r *Registry, types map[reflect.Type]bool) {
+	f.entities.reset()
+	for _, a := range f.archeTypes {
+		a.clear()
+	}
+	f.archeTypes = f.archeTypes[:0]
+
+	for _, src := range r.archeTypeList {
+		if src.getEntityCount() == 0 {
+			continue
+		}
+		dst, found := f.copied[src]
+		if !found || !dst.hasSelectedComponents(src, types) {
+			var selected []reflect.Type
+			for _, t := range src.getComponentTypeList() {
+				if types[t] {
+					selected = append(selected, t)
+				}
+			}
+			if len(selected) == 0 {
+				continue
+			}
+			dst = newArcheTypeWithTable(&f.entities, selected...)
+			f.copied[src] = dst
+		}
+
+		start := dst.addEntities(src.enitityIds)
+		for t := range dst.components {
+			cmp := src.components[t]
+			if cmp != nil {
+				cmp.copyAllToOtherArcheType(dst, start)
+			}
+		}
+		f.archeTypes = append(f.archeTypes, dst)
+	}
+}
+
+// hasSelectedComponents returns true if copied archetype has exactly selected components of src
+func (a *ArcheType) hasSelectedComponents(src *ArcheType, types map[reflect.Type]bool) bool {
+	count := 0
+	for t := range src.components {
+		if !types[t] {
+			continue
+		}
+		if !a.hasComponent(t) {
+			return false
+		}
+		count++
+	}
+	return count == len(a.components)
+}
+
+// publishViews publishes frames of all world views
+func (r *Registry) publishViews() {
+	r.viewMx.Lock()
+	views := r.views
+	r.viewMx.Unlock()
+
+	for _, v := range views {
+		v.publish()
+	}
+}
@@ -0,0 +1,139 @@
+package ecsgo
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorldView(t *testing.T) {
+	r := NewRegistry()
+	defer r.Close()
+	view := r.NewWorldView()
+	AddViewComponent[TestComponent1](view)
+
+	e1 := r.CreateEntity()
+	AddComponent(r, e1, TestComponent1{X: 1})
+	AddComponent(r, e1, TestComponent2{V: 1})
+	e2 := r.CreateEntity()
+	AddComponent(r, e2, TestComponent2{V: 2})
+
+	// nothing is published before Tick
+	assert.Nil(t, view.Acquire())
+
+	sys := r.AddSystem("move", 0, func(ctx *ExecutionContext) error {
+		return ctx.GetQueryResult(0).ForeachEntities(func(accessor *ArcheTypeAccessor) error {
+			GetComponentByAccessor[TestComponent1](accessor).X++
+			return nil
+		})
+	})
+	AddReadWriteComponent[TestComponent1](sys.NewQuery())
+
+	assert.NoError(t, r.Tick(time.Millisecond, context.Background()))
+	frame := view.Acquire()
+	assert.NotNil(t, frame)
+	assert.Equal(t, uint64(1), frame.GetTick())
+	// e2 doesn't have selected component
+	assert.Equal(t, 1, frame.GetEntityCount())
+	assert.Equal(t, 2, GetViewComponent[TestComponent1](frame, e1).X)
+	// not selected
+	assert.Nil(t, GetViewComponent[TestComponent2](frame, e1))
+	assert.Nil(t, GetViewComponent[TestComponent1](frame, e2))
+
+	// acquired frame is not changed by next ticks
+	assert.NoError(t, r.Tick(time.Millisecond, context.Background()))
+	assert.NoError(t, r.Tick(time.Millisecond, context.Background()))
+	assert.Equal(t, 2, GetViewComponent[TestComponent1](frame, e1).X)
+	frame.Release()
+
+	frame = view.Acquire()
+	assert.Equal(t, uint64(3), frame.GetTick())
+	assert.Equal(t, 4, GetViewComponent[TestComponent1](frame, e1).X)
+	frame.Release()
+
+	// removed entity is not in next frame
+	r.RemoveEntity(e1)
+	AddComponent(r, e2, TestComponent1{X: 10})
+	assert.NoError(t, r.Tick(time.Millisecond, context.Background()))
+	frame = view.Acquire()
+	defer frame.Release()
+	assert.Nil(t, GetViewComponent[TestComponent1](frame, e1))
+	assert.Equal(t, 11, GetViewComponent[TestComponent1](frame, e2).X)
+	var ids []EntityId
+	frame.Foreach(func(accessor *ArcheTypeAccessor) error {
+		ids = append(ids, accessor.GetEntityId())
+		return nil
+	})
+	assert.Equal(t, []EntityId{e2}, ids)
+}
+
+func TestWorldViewConcurrentRead(t *testing.T) {
+	r := NewRegistry()
+	defer r.Close()
+	view := r.NewWorldView()
+	AddViewComponent[TestComponent1](view)
+
+	for i := 0; i < 100; i++ {
+		e := r.CreateEntity()
+		AddComponent(r, e, TestComponent1{X: i, Y: i})
+	}
+	sys := r.AddSystem("move", 0, func(ctx *ExecutionContext) error {
+		return ctx.GetQueryResult(0).ForeachEntities(func(accessor *ArcheTypeAccessor) error {
+			c := GetComponentByAccessor[TestComponent1](accessor)
+			c.X++
+			c.Y++
+			return nil
+		})
+	})
+	AddReadWriteComponent[TestComponent1](sys.NewQuery())
+	spawner := r.AddSystem("spawn", 1, func(ctx *ExecutionContext) error {
+		e := ctx.Commands().CreateEntity()
+		AddComponentCommand(ctx.Commands(), e, TestComponent1{})
+		return nil
+	})
+	AddReadonlyComponent[TestComponent2](spawner.NewQuery())
+
+	var stop atomic.Bool
+	var mismatch atomic.Int32
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		lastTick := uint64(0)
+		for !stop.Load() {
+			frame := view.Acquire()
+			if frame == nil {
+				continue
+			}
+			if frame.GetTick() < lastTick {
+				mismatch.Add(1)
+			}
+			lastTick = frame.GetTick()
+			frame.Foreach(func(accessor *ArcheTypeAccessor) error {
+				c := GetComponentByAccessor[TestComponent1](accessor)
+				if c.X != c.Y {
+					mismatch.Add(1)
+				}
+				return nil
+			})
+			frame.Release()
+		}
+	}()
+
+	for i := 0; i < 200; i++ {
+		assert.NoError(t, r.Tick(time.Millisecond, context.Background()))
+	}
+	stop.Store(true)
+	wg.Wait()
+	assert.Equal(t, int32(0), mismatch.Load())
+
+	frame := view.Acquire()
+	defer frame.Release()
+	assert.Equal(t, 300, frame.GetEntityCount())
+	// frames are reused, so only few frames are made
+	assert.LessOrEqual(t, len(view.frames), 3)
+}